@@ -5,9 +5,15 @@ import (
 	"errors"
 	"testing"
 
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 	"gopkg.in/natefinch/lumberjack.v2"
 
 	"github.com/thnthien/great-deku/l2/telegram"
+	"github.com/thnthien/great-deku/trace"
+	"github.com/thnthien/great-deku/trace/pkg/baggage"
+	spancontext "github.com/thnthien/great-deku/trace/pkg/span-context"
 )
 
 func TestNew(t *testing.T) {
@@ -59,3 +65,89 @@ func Test_TelegramLog(t *testing.T) {
 	ll.Info("test info")
 	ll.Error("test error", Error(errors.New("this error is for testing")))
 }
+
+func TestLogCtx_BaggageKeys(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	ll := Logger{Logger: zap.New(core)}
+	WithBaggageKeys("tenant", "missing")(&ll)
+
+	ctx := baggage.WithValue(context.Background(), "tenant", "acme")
+	ll.With(String("k", "v")).InfoCtx(ctx, "with baggage")
+
+	fields := logs.All()[0].ContextMap()
+	if fields["tenant"] != "acme" {
+		t.Fatalf("tenant = %v", fields["tenant"])
+	}
+	if _, ok := fields["missing"]; ok {
+		t.Fatal("absent baggage key logged")
+	}
+}
+
+func TestLogCtx_TraceFields(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	ll := Logger{Logger: zap.New(core)}
+
+	span := testSpan{traceID: "0102030405060708090a0b0c0d0e0f10", spanID: "0102030405060708"}
+	ctx := spancontext.NewContext(context.Background(), span)
+	ll.InfoCtx(ctx, "in span")
+	ll.InfoCtx(context.Background(), "no span")
+	WithTraceFields(false)(&ll)
+	ll.InfoCtx(ctx, "disabled")
+
+	entries := logs.All()
+	fields := entries[0].ContextMap()
+	if fields["trace_id"] != span.GetTraceID() || fields["span_id"] != span.GetSpanID() {
+		t.Fatalf("fields = %v", fields)
+	}
+	for _, e := range entries[1:] {
+		if _, ok := e.ContextMap()["trace_id"]; ok {
+			t.Fatalf("%q: unexpected trace_id", e.Message)
+		}
+	}
+}
+
+// testSpan is the part of a span read by the logger.
+type testSpan struct {
+	trace.ISpan
+	traceID, spanID string
+}
+
+func (s testSpan) GetTraceID() string { return s.traceID }
+func (s testSpan) GetSpanID() string  { return s.spanID }
+
+type eventSpan struct {
+	testSpan
+	events []string
+	attrs  []trace.Attribute
+}
+
+func (s *eventSpan) IsRecordingEvents() bool { return true }
+func (s *eventSpan) EventCount() int         { return len(s.events) }
+func (s *eventSpan) AddEvent(name string, attrs ...trace.Attribute) {
+	s.events = append(s.events, name)
+	s.attrs = append(s.attrs, attrs...)
+}
+
+func TestLogCtx_SpanEvents(t *testing.T) {
+	core, _ := observer.New(zapcore.DebugLevel)
+	ll := Logger{Logger: zap.New(core)}
+	WithSpanEvents(zapcore.InfoLevel, 2)(&ll)
+
+	span := &eventSpan{testSpan: testSpan{traceID: "0102030405060708090a0b0c0d0e0f10", spanID: "0102030405060708"}}
+	ctx := spancontext.NewContext(context.Background(), span)
+	ll.DebugCtx(ctx, "below level")
+	ll.InfoCtx(ctx, "first", Int("n", 1))
+	ll.With(String("k", "v")).WarnCtx(ctx, "second")
+	ll.ErrorCtx(ctx, "over cap")
+
+	if len(span.events) != 2 || span.events[0] != "first" || span.events[1] != "second" {
+		t.Fatalf("events = %v", span.events)
+	}
+	found := map[string]interface{}{}
+	for _, a := range span.attrs {
+		found[a.Key] = a.Value
+	}
+	if found["n"] != int64(1) || found["k"] != "v" || found["log.severity"] == nil {
+		t.Fatalf("attributes = %v", found)
+	}
+}
@@ -3,6 +3,8 @@ package l2
 import (
 	"context"
 	"github.com/thnthien/great-deku/l2/sentry"
+	"github.com/thnthien/great-deku/trace/pkg/baggage"
+	spancontext "github.com/thnthien/great-deku/trace/pkg/span-context"
 	"log"
 
 	"go.uber.org/zap"
@@ -36,6 +38,33 @@ func WithZapOptions(opts []zap.Option) LoggerOption {
 	}
 }
 
+// WithTraceFields sets whether the *Ctx methods add the trace_id and span_id
+// fields of the span found in the context. It is enabled by default.
+func WithTraceFields(enabled bool) LoggerOption {
+	return func(logger *Logger) {
+		logger.disableTraceFields = !enabled
+	}
+}
+
+// WithSpanEvents makes the *Ctx methods append the message and fields of log
+// lines at minLevel or above as events of the recording span found in the
+// context. Log lines are no longer added once the span holds maxPerSpan
+// events, including those not added by the logger. A negative value means
+// unlimited.
+func WithSpanEvents(minLevel zapcore.Level, maxPerSpan int) LoggerOption {
+	return func(logger *Logger) {
+		logger.spanEvents = newSpanEvents(minLevel, maxPerSpan)
+	}
+}
+
+// WithBaggageKeys adds the values of the given baggage keys found in the
+// context to the fields of the *Ctx methods.
+func WithBaggageKeys(keys ...string) LoggerOption {
+	return func(logger *Logger) {
+		logger.baggageKeys = append(logger.baggageKeys, keys...)
+	}
+}
+
 func NewWithSentry(sentryCfg *sentry.Configuration) LoggerOption {
 	return func(logger *Logger) {
 		logger.sentryCfg = sentryCfg
@@ -46,20 +75,30 @@ func NewWithSentry(sentryCfg *sentry.Configuration) LoggerOption {
 type Logger struct {
 	*zap.Logger
 
-	zapOpts         []zap.Option
-	sentryCfg       *sentry.Configuration
-	logLevel        Level
-	requestIDCtxKey string
+	zapOpts            []zap.Option
+	sentryCfg          *sentry.Configuration
+	logLevel           Level
+	requestIDCtxKey    string
+	baggageKeys        []string
+	disableTraceFields bool
+	spanEvents         *spanEvents
+	// withFields are the fields given to With, which the zap core does not
+	// expose, kept for the span events.
+	withFields []zap.Field
 }
 
 func (l *Logger) With(fields ...zap.Field) *Logger {
 	logger := l.Logger.With(fields...)
 	return &Logger{
-		Logger:          logger,
-		zapOpts:         l.zapOpts,
-		sentryCfg:       l.sentryCfg,
-		logLevel:        l.logLevel,
-		requestIDCtxKey: l.requestIDCtxKey,
+		Logger:             logger,
+		zapOpts:            l.zapOpts,
+		sentryCfg:          l.sentryCfg,
+		logLevel:           l.logLevel,
+		requestIDCtxKey:    l.requestIDCtxKey,
+		baggageKeys:        l.baggageKeys,
+		disableTraceFields: l.disableTraceFields,
+		spanEvents:         l.spanEvents,
+		withFields:         append(l.withFields[:len(l.withFields):len(l.withFields)], fields...),
 	}
 }
 
@@ -88,10 +127,23 @@ func (l *Logger) ErrorCtx(ctx context.Context, msg string, fields ...zap.Field)
 }
 
 func (l *Logger) LogCtx(ctx context.Context, level zapcore.Level, msg string, fields ...zap.Field) {
+	if l.spanEvents != nil {
+		l.spanEvents.record(ctx, level, msg, l.withFields, fields)
+	}
 	rid := l.getRequestID(ctx)
 	if rid != "" {
 		fields = append(fields, String("request_id", rid))
 	}
+	if !l.disableTraceFields {
+		if span := spancontext.FromContext(ctx); span != nil && span.GetTraceID() != "" {
+			fields = append(fields, String("trace_id", span.GetTraceID()), String("span_id", span.GetSpanID()))
+		}
+	}
+	for _, k := range l.baggageKeys {
+		if v := baggage.Value(ctx, k); v != "" {
+			fields = append(fields, String(k, v))
+		}
+	}
 
 	var log func(string, ...zap.Field)
 
@@ -0,0 +1,62 @@
+package l2
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/thnthien/great-deku/trace"
+	spancontext "github.com/thnthien/great-deku/trace/pkg/span-context"
+)
+
+// eventCounter is implemented by the spans counting their events, such as
+// those of trace/pkg. The cap of WithSpanEvents only applies to them.
+type eventCounter interface {
+	EventCount() int
+}
+
+// spanEvents records log lines as events of the span found in the context.
+type spanEvents struct {
+	minLevel   zapcore.Level
+	maxPerSpan int
+}
+
+func newSpanEvents(minLevel zapcore.Level, maxPerSpan int) *spanEvents {
+	return &spanEvents{
+		minLevel:   minLevel,
+		maxPerSpan: maxPerSpan,
+	}
+}
+
+// record adds an event holding msg, the fields given to Logger.With and the
+// fields of the log line.
+func (e *spanEvents) record(ctx context.Context, level zapcore.Level, msg string, withFields, fields []zap.Field) {
+	if level < e.minLevel {
+		return
+	}
+	span := spancontext.FromContext(ctx)
+	if span == nil || !span.IsRecordingEvents() || !e.allow(span) {
+		return
+	}
+
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range withFields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	attrs := make([]trace.Attribute, 0, len(enc.Fields)+1)
+	attrs = append(attrs, trace.String("log.severity", level.String()))
+	for k, v := range enc.Fields {
+		attrs = append(attrs, trace.Attribute{Key: k, Value: v})
+	}
+	span.AddEvent(msg, attrs...)
+}
+
+// allow reports whether the span holds fewer events than the cap.
+func (e *spanEvents) allow(span trace.ISpan) bool {
+	c, ok := span.(eventCounter)
+	return !ok || e.maxPerSpan < 0 || c.EventCount() < e.maxPerSpan
+}
@@ -0,0 +1,46 @@
+package trace
+
+// Attribute is a key-value pair attached to a span, an event or a link. Use
+// the typed constructors so that the value is one of the types supported by
+// every exporter.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+func String(key, val string) Attribute {
+	return Attribute{Key: key, Value: val}
+}
+
+func Int64(key string, val int64) Attribute {
+	return Attribute{Key: key, Value: val}
+}
+
+// Int returns an int64 attribute.
+func Int(key string, val int) Attribute {
+	return Attribute{Key: key, Value: int64(val)}
+}
+
+func Float64(key string, val float64) Attribute {
+	return Attribute{Key: key, Value: val}
+}
+
+func Bool(key string, val bool) Attribute {
+	return Attribute{Key: key, Value: val}
+}
+
+func StringSlice(key string, val []string) Attribute {
+	return Attribute{Key: key, Value: append([]string(nil), val...)}
+}
+
+func Int64Slice(key string, val []int64) Attribute {
+	return Attribute{Key: key, Value: append([]int64(nil), val...)}
+}
+
+func Float64Slice(key string, val []float64) Attribute {
+	return Attribute{Key: key, Value: append([]float64(nil), val...)}
+}
+
+func BoolSlice(key string, val []bool) Attribute {
+	return Attribute{Key: key, Value: append([]bool(nil), val...)}
+}
@@ -0,0 +1,37 @@
+// Command chrometrace converts the spans logged by a tracepkg.LogExporter to
+// the Chrome Trace Event Format, to be opened in chrome://tracing or Perfetto.
+//
+// Usage:
+//
+//	chrometrace [log file] > trace.json
+//
+// The log is read from stdin when no file is given.
+package main
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"os"
+
+	tracepkg "github.com/thnthien/great-deku/trace/pkg"
+)
+
+func main() {
+	var in io.Reader = os.Stdin
+	if len(os.Args) > 1 {
+		f, err := os.Open(os.Args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		in = f
+	}
+	out := bufio.NewWriter(os.Stdout)
+	if err := tracepkg.ConvertLogToChromeTrace(in, out); err != nil {
+		log.Fatal(err)
+	}
+	if err := out.Flush(); err != nil {
+		log.Fatal(err)
+	}
+}
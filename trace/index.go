@@ -7,6 +7,7 @@ type ISpan interface {
 	NeedExport(b bool)
 	IsRecordingEvents() bool
 	SetAttribute(key string, val interface{})
+	SetAttributes(attrs ...Attribute)
 	SetError(err error)
 	IsError() bool
 	GetSpanData() ISpanData
@@ -15,6 +16,32 @@ type ISpan interface {
 	End()
 	EndExport()
 	SetWarnDuration(d time.Duration)
+	AddEvent(name string, attrs ...Attribute)
+	AddLink(traceID, spanID string, attrs ...Attribute)
+	SetStatus(code StatusCode, description string)
+}
+
+// StatusCode is the status of a finished span.
+type StatusCode int
+
+const (
+	StatusCodeUnset StatusCode = iota
+	StatusCodeOK
+	StatusCodeError
+)
+
+func (c StatusCode) String() string {
+	switch c {
+	case StatusCodeOK:
+		return "OK"
+	case StatusCodeError:
+		return "ERROR"
+	}
+	return "UNSET"
+}
+
+func (c StatusCode) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
 }
 
 type ISpanData interface {
@@ -0,0 +1,150 @@
+package tracepkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"unicode/utf8"
+
+	"github.com/thnthien/great-deku/trace"
+)
+
+// SpanLimits bounds the data recorded by a span. A negative value means
+// unlimited. WithSpanLimits keeps the default of a zero value, so that only
+// the limits of interest need to be set.
+type SpanLimits struct {
+	// AttributeCountLimit is the number of attributes of a span, and of each
+	// of its events and links.
+	AttributeCountLimit int
+	// AttributeValueLengthLimit is the length in bytes of string values.
+	AttributeValueLengthLimit int
+	EventCountLimit           int
+	LinkCountLimit            int
+}
+
+const (
+	DefaultAttributeCountLimit       = 128
+	DefaultAttributeValueLengthLimit = 4096
+	DefaultEventCountLimit           = 128
+	DefaultLinkCountLimit            = 128
+)
+
+// DefaultSpanLimits returns the limits used by a TracerProvider unless
+// WithSpanLimits is given.
+func DefaultSpanLimits() SpanLimits {
+	return SpanLimits{
+		AttributeCountLimit:       DefaultAttributeCountLimit,
+		AttributeValueLengthLimit: DefaultAttributeValueLengthLimit,
+		EventCountLimit:           DefaultEventCountLimit,
+		LinkCountLimit:            DefaultLinkCountLimit,
+	}
+}
+
+func withinLimit(n, limit int) bool {
+	return limit < 0 || n < limit
+}
+
+// setAttribute stores a normalized value in attrs unless a new key would exceed
+// the count limit. It returns false when the attribute is dropped.
+func (l SpanLimits) setAttribute(attrs map[string]interface{}, key string, val interface{}) bool {
+	if _, ok := attrs[key]; !ok && !withinLimit(len(attrs), l.AttributeCountLimit) {
+		return false
+	}
+	attrs[key] = normalizeValue(val, l.AttributeValueLengthLimit)
+	return true
+}
+
+// attributesMap returns the attributes as a map, and the number of attributes
+// dropped by the limits.
+func (l SpanLimits) attributesMap(attrs []trace.Attribute) (map[string]interface{}, int) {
+	if len(attrs) == 0 {
+		return nil, 0
+	}
+	dropped := 0
+	m := make(map[string]interface{}, len(attrs))
+	for _, a := range attrs {
+		if !l.setAttribute(m, a.Key, a.Value) {
+			dropped++
+		}
+	}
+	return m, dropped
+}
+
+// normalizeValue converts v to one of the types created by the constructors of
+// package trace, truncating strings to maxLen bytes. Values of other types are
+// rendered as strings.
+func normalizeValue(v interface{}, maxLen int) interface{} {
+	switch v := v.(type) {
+	case string:
+		return truncate(v, maxLen)
+	case bool, int64, float64:
+		return v
+	case []bool:
+		return append([]bool(nil), v...)
+	case []int64:
+		return append([]int64(nil), v...)
+	case []float64:
+		return append([]float64(nil), v...)
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint:
+		return uintValue(uint64(v), maxLen)
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		return uintValue(v, maxLen)
+	case float32:
+		return float64(v)
+	case []string:
+		out := make([]string, len(v))
+		for i, s := range v {
+			out[i] = truncate(s, maxLen)
+		}
+		return out
+	case []int:
+		out := make([]int64, len(v))
+		for i, n := range v {
+			out[i] = int64(n)
+		}
+		return out
+	case error:
+		return truncate(v.Error(), maxLen)
+	case fmt.Stringer:
+		return truncate(v.String(), maxLen)
+	case nil:
+		return ""
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return truncate(fmt.Sprint(v), maxLen)
+	}
+	return truncate(string(b), maxLen)
+}
+
+func uintValue(v uint64, maxLen int) interface{} {
+	if v > math.MaxInt64 {
+		return truncate(fmt.Sprint(v), maxLen)
+	}
+	return int64(v)
+}
+
+// truncate cuts s to at most maxLen bytes without splitting a rune.
+func truncate(s string, maxLen int) string {
+	if maxLen < 0 || len(s) <= maxLen {
+		return s
+	}
+	for maxLen > 0 && !utf8.RuneStart(s[maxLen]) {
+		maxLen--
+	}
+	return s[:maxLen]
+}
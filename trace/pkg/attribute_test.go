@@ -0,0 +1,141 @@
+package tracepkg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/thnthien/great-deku/trace"
+	spancontext "github.com/thnthien/great-deku/trace/pkg/span-context"
+)
+
+func TestSpan_TypedAttributes(t *testing.T) {
+	_, span := StartSpan(context.Background(), "typed")
+	span.SetAttributes(
+		trace.String("s", "v"),
+		trace.Int("i", 1),
+		trace.Float64("f", 1.5),
+		trace.Bool("b", true),
+		trace.StringSlice("ss", []string{"a"}),
+		trace.Int64Slice("is", []int64{1, 2}),
+	)
+	span.SetAttribute("int32", int32(7))
+	span.SetAttribute("duration", 2*time.Second)
+	span.SetAttribute("struct", struct{ A int }{A: 1})
+	span.End()
+
+	attrs := span.GetSpanData().(*SpanData).Attributes
+	want := map[string]interface{}{
+		"s":        "v",
+		"i":        int64(1),
+		"f":        1.5,
+		"b":        true,
+		"int32":    int64(7),
+		"duration": "2s",
+		"struct":   `{"A":1}`,
+	}
+	for k, v := range want {
+		if attrs[k] != v {
+			t.Errorf("%s = %#v, want %#v", k, attrs[k], v)
+		}
+	}
+	if ss, ok := attrs["ss"].([]string); !ok || len(ss) != 1 {
+		t.Errorf("ss = %#v", attrs["ss"])
+	}
+}
+
+func TestSpan_SliceAttributesCopied(t *testing.T) {
+	exp := &collectExporter{}
+	_, span := NewTracerProvider(WithExporters(exp)).Tracer("").Start(context.Background(), "slices")
+	ints, floats, bools := []int64{1, 2}, []float64{1.5}, []bool{true}
+	span.SetAttribute("ints", ints)
+	span.SetAttribute("floats", floats)
+	span.SetAttribute("bools", bools)
+	span.End()
+	ints[0], floats[0], bools[0] = 99, 9.5, false
+
+	attrs := exp.byName("slices").Attributes
+	if attrs["ints"].([]int64)[0] != 1 || attrs["floats"].([]float64)[0] != 1.5 || !attrs["bools"].([]bool)[0] {
+		t.Fatalf("exported attributes changed with the caller's slices: %v", attrs)
+	}
+}
+
+func TestSpan_Limits(t *testing.T) {
+	exp := &collectExporter{}
+	p := NewTracerProvider(WithExporters(exp), WithSpanLimits(SpanLimits{
+		AttributeCountLimit:       2,
+		AttributeValueLengthLimit: 4,
+		EventCountLimit:           1,
+		LinkCountLimit:            1,
+	}))
+
+	sc := spancontext.SpanContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7"}
+	_, span := p.Tracer("").Start(context.Background(), "limited",
+		WithLinks(NewLink(sc, trace.String("k", "value"), trace.Int("x", 1), trace.Int("y", 2))))
+	span.SetAttribute("a", "héllo")
+	span.SetAttribute("b", []string{"abcdef"})
+	span.SetAttribute("c", "dropped")
+	span.SetAttribute("a", "updated")
+	span.AddEvent("first", trace.Int("x", 1), trace.Int("y", 2), trace.Int("z", 3))
+	span.AddEvent("second")
+	span.AddLink("4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b8")
+	span.End()
+
+	sd := exp.byName("limited")
+	if len(sd.Attributes) != 2 || sd.DroppedAttributeCount != 1 {
+		t.Fatalf("attributes = %v, dropped = %d", sd.Attributes, sd.DroppedAttributeCount)
+	}
+	if sd.Attributes["a"] != "upda" {
+		t.Fatalf("a = %q, want truncated value", sd.Attributes["a"])
+	}
+	if b := sd.Attributes["b"].([]string); b[0] != "abcd" {
+		t.Fatalf("b = %q", b)
+	}
+	if len(sd.Events) != 1 || sd.DroppedEventCount != 1 || sd.Events[0].DroppedAttributeCount != 1 {
+		t.Fatalf("events = %+v, dropped = %d", sd.Events, sd.DroppedEventCount)
+	}
+	if len(sd.Links) != 1 || sd.DroppedLinkCount != 1 {
+		t.Fatalf("links = %+v, dropped = %d", sd.Links, sd.DroppedLinkCount)
+	}
+	if l := sd.Links[0]; len(l.Attributes) != 2 || l.DroppedAttributeCount != 1 || l.Attributes["k"] != "valu" {
+		t.Fatalf("link attributes = %v, dropped = %d", l.Attributes, l.DroppedAttributeCount)
+	}
+	if s := otlpSpanFromData(sd); s.DroppedAttributesCount != 1 || s.DroppedEventsCount != 1 || s.DroppedLinksCount != 1 {
+		t.Fatalf("otlp dropped counts = %d/%d/%d", s.DroppedAttributesCount, s.DroppedEventsCount, s.DroppedLinksCount)
+	}
+}
+
+func TestWithSpanLimits_Partial(t *testing.T) {
+	exp := &collectExporter{}
+	p := NewTracerProvider(WithExporters(exp), WithSpanLimits(SpanLimits{EventCountLimit: 1}))
+
+	_, span := p.Tracer("").Start(context.Background(), "partial")
+	span.SetAttribute("a", "kept")
+	span.AddEvent("first")
+	span.AddEvent("second")
+	span.AddLink("4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7")
+	if n := span.(*Span).EventCount(); n != 2 {
+		t.Fatalf("EventCount() = %d, want 2", n)
+	}
+	span.End()
+
+	sd := exp.byName("partial")
+	if sd.Attributes["a"] != "kept" || sd.DroppedAttributeCount != 0 {
+		t.Fatalf("attributes = %v, dropped = %d", sd.Attributes, sd.DroppedAttributeCount)
+	}
+	if len(sd.Events) != 1 || sd.DroppedEventCount != 1 {
+		t.Fatalf("events = %+v, dropped = %d", sd.Events, sd.DroppedEventCount)
+	}
+	if len(sd.Links) != 1 || sd.DroppedLinkCount != 0 {
+		t.Fatalf("links = %+v, dropped = %d", sd.Links, sd.DroppedLinkCount)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("héllo", 2); got != "h" {
+		t.Fatalf("truncate split a rune: %q", got)
+	}
+	if got := truncate("hello", -1); got != "hello" {
+		t.Fatalf("unlimited truncate = %q", got)
+	}
+}
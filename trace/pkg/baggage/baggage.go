@@ -0,0 +1,156 @@
+// Package baggage carries application defined key-values, such as a tenant ID,
+// across services alongside the span context, using the W3C baggage header.
+package baggage
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	header = "baggage"
+
+	maxMembers     = 180
+	maxHeaderBytes = 8192
+)
+
+type contextKey struct{}
+
+// Carrier is the storage medium the baggage header is read from and written
+// to, such as tracepkg.HeaderCarrier.
+type Carrier interface {
+	Get(key string) string
+	Set(key, value string)
+}
+
+// FromContext returns a copy of the baggage stored in ctx.
+func FromContext(ctx context.Context) map[string]string {
+	b := load(ctx)
+	out := make(map[string]string, len(b))
+	for k, v := range b {
+		out[k] = v
+	}
+	return out
+}
+
+// Value returns the value of key in the baggage of ctx, or "" if it is not set.
+func Value(ctx context.Context, key string) string {
+	return load(ctx)[key]
+}
+
+// WithValue returns a copy of ctx whose baggage has key set to value.
+func WithValue(ctx context.Context, key, value string) context.Context {
+	return WithValues(ctx, map[string]string{key: value})
+}
+
+// WithValues returns a copy of ctx whose baggage has the given key-values set.
+func WithValues(ctx context.Context, kvs map[string]string) context.Context {
+	old := load(ctx)
+	b := make(map[string]string, len(old)+len(kvs))
+	for k, v := range old {
+		b[k] = v
+	}
+	for k, v := range kvs {
+		if isToken(k) {
+			b[k] = v
+		}
+	}
+	return context.WithValue(ctx, contextKey{}, b)
+}
+
+// Delete returns a copy of ctx whose baggage does not contain key.
+func Delete(ctx context.Context, key string) context.Context {
+	old := load(ctx)
+	if _, ok := old[key]; !ok {
+		return ctx
+	}
+	b := make(map[string]string, len(old))
+	for k, v := range old {
+		if k != key {
+			b[k] = v
+		}
+	}
+	return context.WithValue(ctx, contextKey{}, b)
+}
+
+// Inject writes the baggage of ctx to the baggage header of carrier.
+func Inject(ctx context.Context, carrier Carrier) {
+	b := load(ctx)
+	if len(b) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(b))
+	for k := range b {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for i, k := range keys {
+		if i == maxMembers {
+			break
+		}
+		member := k + "=" + url.PathEscape(b[k])
+		if sb.Len()+len(member)+1 > maxHeaderBytes {
+			break
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(member)
+	}
+	carrier.Set(header, sb.String())
+}
+
+// Extract returns a copy of ctx whose baggage contains the members of the
+// baggage header of carrier. Malformed members are skipped.
+func Extract(ctx context.Context, carrier Carrier) context.Context {
+	h := carrier.Get(header)
+	if h == "" || len(h) > maxHeaderBytes {
+		return ctx
+	}
+	kvs := make(map[string]string)
+	for i, member := range strings.Split(h, ",") {
+		if i == maxMembers {
+			break
+		}
+		// properties are not supported
+		if j := strings.IndexByte(member, ';'); j >= 0 {
+			member = member[:j]
+		}
+		k, v, ok := strings.Cut(member, "=")
+		if !ok {
+			continue
+		}
+		k = strings.TrimSpace(k)
+		v, err := url.PathUnescape(strings.TrimSpace(v))
+		if err != nil || !isToken(k) {
+			continue
+		}
+		kvs[k] = v
+	}
+	if len(kvs) == 0 {
+		return ctx
+	}
+	return WithValues(ctx, kvs)
+}
+
+func load(ctx context.Context) map[string]string {
+	b, _ := ctx.Value(contextKey{}).(map[string]string)
+	return b
+}
+
+// isToken reports whether s is a valid RFC 7230 token.
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c <= ' ' || c >= 0x7f || strings.ContainsRune("\"(),/:;<=>?@[\\]{}", c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package baggage
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+type headerCarrier http.Header
+
+func (c headerCarrier) Get(key string) string { return http.Header(c).Get(key) }
+func (c headerCarrier) Set(key, value string) { http.Header(c).Set(key, value) }
+
+func TestInjectExtract(t *testing.T) {
+	ctx := WithValue(context.Background(), "tenant", "acme corp")
+	ctx = WithValues(ctx, map[string]string{"exp": "a,b;c", "bad key": "x"})
+	if Value(ctx, "bad key") != "" {
+		t.Fatal("invalid key accepted")
+	}
+
+	h := http.Header{}
+	Inject(ctx, headerCarrier(h))
+	if got := h.Get("baggage"); got != "exp=a%2Cb%3Bc,tenant=acme%20corp" {
+		t.Fatalf("baggage header = %q", got)
+	}
+
+	got := FromContext(Extract(context.Background(), headerCarrier(h)))
+	if len(got) != 2 || got["tenant"] != "acme corp" || got["exp"] != "a,b;c" {
+		t.Fatalf("extracted baggage = %v", got)
+	}
+}
+
+func TestExtract_Malformed(t *testing.T) {
+	h := http.Header{}
+	h.Set("baggage", "k1=v1;prop=1, novalue , k2 = v%202 ,=v3,k4=%zz")
+	got := FromContext(Extract(context.Background(), headerCarrier(h)))
+	if len(got) != 2 || got["k1"] != "v1" || got["k2"] != "v 2" {
+		t.Fatalf("extracted baggage = %v", got)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	ctx := WithValue(context.Background(), "k", "v")
+	parent := ctx
+	ctx = Delete(ctx, "k")
+	if Value(ctx, "k") != "" || Value(parent, "k") != "v" {
+		t.Fatal("Delete must not modify the parent context")
+	}
+}
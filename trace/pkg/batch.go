@@ -0,0 +1,291 @@
+package tracepkg
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BatchExporter exports ended spans in batches.
+type BatchExporter interface {
+	ExportSpans(ctx context.Context, spans []*SpanData) error
+}
+
+// DropPolicy decides which span is dropped when the queue of a BatchProcessor
+// is full.
+type DropPolicy uint8
+
+const (
+	// DropNewest drops the span being enqueued.
+	DropNewest DropPolicy = iota
+	// DropOldest drops the oldest queued span to make room for the new one.
+	DropOldest
+)
+
+var ErrProcessorShutdown = errors.New("batch processor is shut down")
+
+type batchConfig struct {
+	maxQueueSize       int
+	maxExportBatchSize int
+	batchTimeout       time.Duration
+	exportTimeout      time.Duration
+	dropPolicy         DropPolicy
+	errorHandler       func(err error)
+}
+
+// BatchOption configures a BatchProcessor.
+type BatchOption func(c *batchConfig)
+
+// WithMaxQueueSize sets the number of spans buffered before spans are dropped.
+// The default is 2048.
+func WithMaxQueueSize(n int) BatchOption {
+	return func(c *batchConfig) {
+		c.maxQueueSize = n
+	}
+}
+
+// WithMaxExportBatchSize sets the number of spans that triggers an export.
+// The default is 512.
+func WithMaxExportBatchSize(n int) BatchOption {
+	return func(c *batchConfig) {
+		c.maxExportBatchSize = n
+	}
+}
+
+// WithBatchTimeout sets the maximum delay before buffered spans are exported.
+// The default is 5s.
+func WithBatchTimeout(d time.Duration) BatchOption {
+	return func(c *batchConfig) {
+		c.batchTimeout = d
+	}
+}
+
+// WithExportTimeout sets the deadline of a single export. The default is 30s.
+func WithExportTimeout(d time.Duration) BatchOption {
+	return func(c *batchConfig) {
+		c.exportTimeout = d
+	}
+}
+
+// WithDropPolicy sets the behaviour when the queue is full. The default is
+// DropNewest.
+func WithDropPolicy(p DropPolicy) BatchOption {
+	return func(c *batchConfig) {
+		c.dropPolicy = p
+	}
+}
+
+// WithBatchErrorHandler sets a function called with every export error.
+func WithBatchErrorHandler(f func(err error)) BatchOption {
+	return func(c *batchConfig) {
+		c.errorHandler = f
+	}
+}
+
+// BatchProcessor is an Exporter that queues ended spans and hands them to a
+// BatchExporter on a background goroutine, either when a batch is full or when
+// the batch timeout expires.
+type BatchProcessor struct {
+	exporter BatchExporter
+	cfg      batchConfig
+
+	queue   chan *SpanData
+	flushCh chan chan struct{}
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+
+	// mu makes the enqueue of ExportSpan and the stop of Shutdown exclusive,
+	// so that no span is queued after the final drain.
+	mu       sync.RWMutex
+	stopOnce sync.Once
+	stopped  int32
+	dropped  uint64
+	exported uint64
+	failed   uint64
+}
+
+// NewBatchProcessor creates a BatchProcessor and starts its background
+// goroutine.
+func NewBatchProcessor(exporter BatchExporter, opts ...BatchOption) *BatchProcessor {
+	cfg := batchConfig{
+		maxQueueSize:       2048,
+		maxExportBatchSize: 512,
+		batchTimeout:       5 * time.Second,
+		exportTimeout:      30 * time.Second,
+		dropPolicy:         DropNewest,
+		errorHandler:       func(err error) {},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.maxQueueSize <= 0 {
+		cfg.maxQueueSize = 2048
+	}
+	if cfg.batchTimeout <= 0 {
+		cfg.batchTimeout = 5 * time.Second
+	}
+	if cfg.exportTimeout <= 0 {
+		cfg.exportTimeout = 30 * time.Second
+	}
+	if cfg.maxExportBatchSize <= 0 || cfg.maxExportBatchSize > cfg.maxQueueSize {
+		cfg.maxExportBatchSize = cfg.maxQueueSize
+	}
+
+	b := &BatchProcessor{
+		exporter: exporter,
+		cfg:      cfg,
+		queue:    make(chan *SpanData, cfg.maxQueueSize),
+		flushCh:  make(chan chan struct{}),
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// Start registers the processor as a global exporter.
+func (b *BatchProcessor) Start() {
+	RegisterExporter(b)
+}
+
+// ExportSpan enqueues sd without blocking. It is dropped if the queue is full
+// (according to the drop policy) or the processor is shut down.
+func (b *BatchProcessor) ExportSpan(sd *SpanData) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if atomic.LoadInt32(&b.stopped) == 1 {
+		atomic.AddUint64(&b.dropped, 1)
+		return
+	}
+	select {
+	case b.queue <- sd:
+		return
+	default:
+	}
+	if b.cfg.dropPolicy == DropOldest {
+		select {
+		case <-b.queue:
+			atomic.AddUint64(&b.dropped, 1)
+		default:
+		}
+		select {
+		case b.queue <- sd:
+			return
+		default:
+		}
+	}
+	atomic.AddUint64(&b.dropped, 1)
+}
+
+// Dropped returns the number of spans dropped so far.
+func (b *BatchProcessor) Dropped() uint64 {
+	return atomic.LoadUint64(&b.dropped)
+}
+
+// Exported returns the number of spans exported successfully so far.
+func (b *BatchProcessor) Exported() uint64 {
+	return atomic.LoadUint64(&b.exported)
+}
+
+// Failed returns the number of spans of the batches whose export returned an
+// error so far.
+func (b *BatchProcessor) Failed() uint64 {
+	return atomic.LoadUint64(&b.failed)
+}
+
+// ForceFlush exports every queued span and waits for the export to finish.
+func (b *BatchProcessor) ForceFlush(ctx context.Context) error {
+	if atomic.LoadInt32(&b.stopped) == 1 {
+		return ErrProcessorShutdown
+	}
+	done := make(chan struct{})
+	select {
+	case b.flushCh <- done:
+	case <-b.doneCh:
+		return ErrProcessorShutdown
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown stops accepting spans, exports the queued ones and stops the
+// background goroutine. It is safe to call more than once.
+func (b *BatchProcessor) Shutdown(ctx context.Context) error {
+	b.stopOnce.Do(func() {
+		b.mu.Lock()
+		atomic.StoreInt32(&b.stopped, 1)
+		b.mu.Unlock()
+		close(b.stopCh)
+	})
+	select {
+	case <-b.doneCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *BatchProcessor) run() {
+	defer close(b.doneCh)
+	ticker := time.NewTicker(b.cfg.batchTimeout)
+	defer ticker.Stop()
+
+	batch := make([]*SpanData, 0, b.cfg.maxExportBatchSize)
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), b.cfg.exportTimeout)
+		if err := b.exporter.ExportSpans(ctx, batch); err != nil {
+			atomic.AddUint64(&b.failed, uint64(len(batch)))
+			b.cfg.errorHandler(err)
+		} else {
+			atomic.AddUint64(&b.exported, uint64(len(batch)))
+		}
+		cancel()
+		batch = make([]*SpanData, 0, b.cfg.maxExportBatchSize)
+	}
+	// drain exports at most the spans queued when it is called, so that it
+	// ends even when spans keep being queued. The queue may still empty
+	// earlier, since DropOldest takes spans from it too.
+	drain := func() {
+		for n := len(b.queue); n > 0; n-- {
+			select {
+			case sd := <-b.queue:
+				batch = append(batch, sd)
+				if len(batch) >= b.cfg.maxExportBatchSize {
+					export()
+				}
+			default:
+				n = 1
+			}
+		}
+		export()
+	}
+
+	for {
+		select {
+		case sd := <-b.queue:
+			batch = append(batch, sd)
+			if len(batch) >= b.cfg.maxExportBatchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case done := <-b.flushCh:
+			drain()
+			close(done)
+		case <-b.stopCh:
+			drain()
+			return
+		}
+	}
+}
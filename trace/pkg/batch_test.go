@@ -0,0 +1,157 @@
+package tracepkg
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type batchRecorder struct {
+	mu      sync.Mutex
+	batches [][]*SpanData
+	entered chan struct{} // signaled without blocking when an export starts
+	block   chan struct{}
+}
+
+func (r *batchRecorder) ExportSpans(_ context.Context, spans []*SpanData) error {
+	select {
+	case r.entered <- struct{}{}:
+	default:
+	}
+	if r.block != nil {
+		<-r.block
+	}
+	r.mu.Lock()
+	r.batches = append(r.batches, spans)
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *batchRecorder) count() (batches, spans int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, b := range r.batches {
+		spans += len(b)
+	}
+	return len(r.batches), spans
+}
+
+func TestBatchProcessor_BySize(t *testing.T) {
+	rec := &batchRecorder{}
+	bp := NewBatchProcessor(rec, WithMaxExportBatchSize(2), WithBatchTimeout(time.Hour))
+	defer bp.Shutdown(context.Background())
+
+	for i := 0; i < 4; i++ {
+		bp.ExportSpan(&SpanData{Name: "s"})
+	}
+	if err := bp.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if b, s := rec.count(); b != 2 || s != 4 {
+		t.Fatalf("got %d batches with %d spans, want 2 with 4", b, s)
+	}
+}
+
+func TestBatchProcessor_ByInterval(t *testing.T) {
+	rec := &batchRecorder{}
+	bp := NewBatchProcessor(rec, WithBatchTimeout(10*time.Millisecond))
+	defer bp.Shutdown(context.Background())
+
+	bp.ExportSpan(&SpanData{Name: "s"})
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if _, s := rec.count(); s == 1 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("span not exported after batch timeout")
+}
+
+func TestBatchProcessor_Drop(t *testing.T) {
+	for _, policy := range []DropPolicy{DropNewest, DropOldest} {
+		rec := &batchRecorder{entered: make(chan struct{}, 1), block: make(chan struct{})}
+		bp := NewBatchProcessor(rec, WithMaxQueueSize(2), WithMaxExportBatchSize(1), WithDropPolicy(policy))
+
+		// the first span is taken by the worker, which then blocks exporting it
+		bp.ExportSpan(&SpanData{Name: "first"})
+		<-rec.entered
+		for i := 0; i < 5; i++ {
+			bp.ExportSpan(&SpanData{Name: "s"})
+		}
+		if bp.Dropped() != 3 {
+			t.Fatalf("policy %d: dropped %d, want 3", policy, bp.Dropped())
+		}
+		close(rec.block)
+		if err := bp.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if _, s := rec.count(); s != 3 {
+			t.Fatalf("policy %d: exported %d spans, want 3", policy, s)
+		}
+		bp.ExportSpan(&SpanData{Name: "late"})
+		if bp.Dropped() != 4 {
+			t.Fatalf("policy %d: span after shutdown not dropped", policy)
+		}
+		if err := bp.ForceFlush(context.Background()); err != ErrProcessorShutdown {
+			t.Fatalf("policy %d: ForceFlush after shutdown = %v", policy, err)
+		}
+	}
+}
+
+func TestBatchProcessor_ShutdownWhileExporting(t *testing.T) {
+	rec := &batchRecorder{}
+	bp := NewBatchProcessor(rec, WithBatchTimeout(time.Hour))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				bp.ExportSpan(&SpanData{Name: "s"})
+			}
+		}()
+	}
+	if err := bp.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if _, s := rec.count(); uint64(s)+bp.Dropped() != 400 {
+		t.Fatalf("exported %d and dropped %d of 400 spans", s, bp.Dropped())
+	}
+}
+
+type failingBatchExporter struct {
+	expired bool
+}
+
+func (e *failingBatchExporter) ExportSpans(ctx context.Context, _ []*SpanData) error {
+	e.expired = ctx.Err() != nil
+	return errors.New("collector unavailable")
+}
+
+func TestBatchProcessor_Failed(t *testing.T) {
+	exp := &failingBatchExporter{}
+	var handled int
+	bp := NewBatchProcessor(exp,
+		WithBatchTimeout(time.Hour),
+		WithExportTimeout(0),
+		WithBatchErrorHandler(func(err error) { handled++ }),
+	)
+	defer bp.Shutdown(context.Background())
+
+	bp.ExportSpan(&SpanData{Name: "s"})
+	bp.ExportSpan(&SpanData{Name: "s"})
+	if err := bp.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if bp.Failed() != 2 || bp.Exported() != 0 || handled != 1 {
+		t.Fatalf("failed = %d, exported = %d, handled = %d", bp.Failed(), bp.Exported(), handled)
+	}
+	if exp.expired {
+		t.Fatal("zero export timeout gave an expired context")
+	}
+}
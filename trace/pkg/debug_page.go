@@ -0,0 +1,286 @@
+package tracepkg
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// debugLatencyBounds are the upper bounds of the latency buckets of the
+// DebugPage. The last bucket holds the spans slower than the last bound.
+var debugLatencyBounds = []time.Duration{
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+	100 * time.Second,
+}
+
+type debugPageConfig struct {
+	sampleSize int
+	maxNames   int
+}
+
+// DebugPageOption configures a DebugPage.
+type DebugPageOption func(c *debugPageConfig)
+
+// WithDebugSampleSize sets the number of recent, slowest and errored spans
+// kept for each span name. The default is 10.
+func WithDebugSampleSize(n int) DebugPageOption {
+	return func(c *debugPageConfig) {
+		c.sampleSize = n
+	}
+}
+
+// WithDebugMaxNames sets the number of span names tracked. Spans with other
+// names are ignored once it is reached. The default is 1000.
+func WithDebugMaxNames(n int) DebugPageOption {
+	return func(c *debugPageConfig) {
+		c.maxNames = n
+	}
+}
+
+// DebugPage is an Exporter keeping, for every span name, the number of spans
+// in flight, a latency histogram and samples of the recent, slowest and
+// errored spans. It serves them over HTTP as HTML, or as JSON when the format
+// query parameter is "json". The name query parameter selects the samples of
+// one span name.
+type DebugPage struct {
+	cfg debugPageConfig
+
+	mu    sync.Mutex
+	names map[string]*debugSpanStats
+}
+
+type debugSpanStats struct {
+	inFlight int64
+	count    uint64
+	errors   uint64
+	buckets  []uint64
+	recent   debugRing
+	errored  debugRing
+	slowest  []*SpanData // sorted, slowest first
+}
+
+// debugRing holds the last spans added to it.
+type debugRing struct {
+	spans []*SpanData
+	next  int
+}
+
+func (r *debugRing) add(sd *SpanData, size int) {
+	if len(r.spans) < size {
+		r.spans = append(r.spans, sd)
+		return
+	}
+	r.spans[r.next] = sd
+	r.next = (r.next + 1) % size
+}
+
+// list returns the spans, most recent first.
+func (r *debugRing) list() []*SpanData {
+	out := make([]*SpanData, 0, len(r.spans))
+	for i := 1; i <= len(r.spans); i++ {
+		out = append(out, r.spans[(r.next-i+len(r.spans))%len(r.spans)])
+	}
+	return out
+}
+
+// NewDebugPage creates a DebugPage. It records nothing until it is registered
+// as an exporter, with Start or WithExporters.
+func NewDebugPage(opts ...DebugPageOption) *DebugPage {
+	cfg := debugPageConfig{
+		sampleSize: 10,
+		maxNames:   1000,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.sampleSize <= 0 {
+		cfg.sampleSize = 10
+	}
+	if cfg.maxNames <= 0 {
+		cfg.maxNames = 1000
+	}
+	return &DebugPage{
+		cfg:   cfg,
+		names: make(map[string]*debugSpanStats),
+	}
+}
+
+// Start registers the page as a global exporter.
+func (d *DebugPage) Start() {
+	RegisterExporter(d)
+}
+
+// SpanStarted implements SpanStartObserver.
+func (d *DebugPage) SpanStarted(name string) {
+	d.mu.Lock()
+	if s := d.statsLocked(name); s != nil {
+		s.inFlight++
+	}
+	d.mu.Unlock()
+}
+
+// ExportSpan implements Exporter.
+func (d *DebugPage) ExportSpan(sd *SpanData) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := d.statsLocked(sd.Name)
+	if s == nil {
+		return
+	}
+	if s.inFlight > 0 {
+		s.inFlight--
+	}
+	s.count++
+	s.buckets[debugBucket(sd.DurationVal)]++
+	s.recent.add(sd, d.cfg.sampleSize)
+	if sd.IsError() {
+		s.errors++
+		s.errored.add(sd, d.cfg.sampleSize)
+	}
+
+	i := sort.Search(len(s.slowest), func(i int) bool {
+		return s.slowest[i].DurationVal < sd.DurationVal
+	})
+	if i < d.cfg.sampleSize {
+		if len(s.slowest) < d.cfg.sampleSize {
+			s.slowest = append(s.slowest, nil)
+		}
+		copy(s.slowest[i+1:], s.slowest[i:])
+		s.slowest[i] = sd
+	}
+}
+
+func (d *DebugPage) statsLocked(name string) *debugSpanStats {
+	s, ok := d.names[name]
+	if !ok {
+		if len(d.names) >= d.cfg.maxNames {
+			return nil
+		}
+		s = &debugSpanStats{buckets: make([]uint64, len(debugLatencyBounds)+1)}
+		d.names[name] = s
+	}
+	return s
+}
+
+func debugBucket(d time.Duration) int {
+	return sort.Search(len(debugLatencyBounds), func(i int) bool {
+		return d <= debugLatencyBounds[i]
+	})
+}
+
+// DebugLatencyBucket is the number of spans whose duration is at most Le, or
+// above every bound when Le is empty.
+type DebugLatencyBucket struct {
+	Le    string `json:"le,omitempty"`
+	Count uint64 `json:"count"`
+}
+
+// DebugSpanSummary describes the spans of a name.
+type DebugSpanSummary struct {
+	Name     string               `json:"name"`
+	InFlight int64                `json:"inFlight"`
+	Count    uint64               `json:"count"`
+	Errors   uint64               `json:"errors"`
+	Latency  []DebugLatencyBucket `json:"latency"`
+	Recent   []*SpanData          `json:"recent,omitempty"`
+	Slowest  []*SpanData          `json:"slowest,omitempty"`
+	Errored  []*SpanData          `json:"errored,omitempty"`
+}
+
+// Summaries returns the statistics of every span name, sorted by name. The
+// samples are included only for the given name.
+func (d *DebugPage) Summaries(withSamples string) []DebugSpanSummary {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]DebugSpanSummary, 0, len(d.names))
+	for name, s := range d.names {
+		sum := DebugSpanSummary{
+			Name:     name,
+			InFlight: s.inFlight,
+			Count:    s.count,
+			Errors:   s.errors,
+			Latency:  make([]DebugLatencyBucket, len(s.buckets)),
+		}
+		for i, n := range s.buckets {
+			sum.Latency[i].Count = n
+			if i < len(debugLatencyBounds) {
+				sum.Latency[i].Le = debugLatencyBounds[i].String()
+			}
+		}
+		if name == withSamples {
+			sum.Recent = s.recent.list()
+			sum.Slowest = append([]*SpanData(nil), s.slowest...)
+			sum.Errored = s.errored.list()
+		}
+		out = append(out, sum)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// ServeHTTP serves the statistics, as JSON if the format query parameter is
+// "json" or the request accepts application/json, and as HTML otherwise.
+func (d *DebugPage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	summaries := d.Summaries(name)
+	if name != "" {
+		for _, s := range summaries {
+			if s.Name == name {
+				summaries = []DebugSpanSummary{s}
+				break
+			}
+		}
+	}
+
+	if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(summaries)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = debugPageTemplate.Execute(w, struct {
+		Bounds    []time.Duration
+		Summaries []DebugSpanSummary
+		Name      string
+	}{debugLatencyBounds, summaries, name})
+}
+
+var debugPageTemplate = template.Must(template.New("spans").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Spans</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 2px 6px; text-align: right; }
+td.name { text-align: left; }
+</style>
+</head>
+<body>
+<h1>Spans</h1>
+<table>
+<tr><th>Name</th><th>In flight</th><th>Count</th><th>Errors</th>{{range .Bounds}}<th>&le;{{.}}</th>{{end}}<th>more</th></tr>
+{{range .Summaries}}<tr><td class="name"><a href="?name={{.Name}}">{{.Name}}</a></td><td>{{.InFlight}}</td><td>{{.Count}}</td><td>{{.Errors}}</td>{{range .Latency}}<td>{{.Count}}</td>{{end}}</tr>
+{{end}}</table>
+{{range .Summaries}}{{if .Recent}}
+<h2>{{.Name}}</h2>
+<h3>Recent</h3>{{template "samples" .Recent}}
+<h3>Slowest</h3>{{template "samples" .Slowest}}
+<h3>Errored</h3>{{template "samples" .Errored}}
+{{end}}{{end}}
+</body>
+</html>
+{{define "samples"}}<table>
+<tr><th>Start</th><th>Duration</th><th>Trace ID</th><th>Span ID</th><th>Parent</th><th>Status</th><th>Attributes</th></tr>
+{{range .}}<tr><td>{{.StartTime.Format "15:04:05.000"}}</td><td>{{.DurationVal}}</td><td class="name">{{.TraceID}}</td><td class="name">{{.SpanID}}</td><td class="name">{{.ParentSpanID}}</td><td>{{.Status.Code}} {{.Status.Description}}</td><td class="name">{{.Attributes}}</td></tr>
+{{end}}</table>{{end}}
+`))
@@ -0,0 +1,68 @@
+package tracepkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebugPage(t *testing.T) {
+	page := NewDebugPage(WithDebugSampleSize(2))
+	tr := NewTracerProvider(WithExporters(page)).Tracer("")
+
+	_, inFlight := tr.Start(context.Background(), "query")
+	defer inFlight.End()
+	for i := 0; i < 3; i++ {
+		_, span := tr.Start(context.Background(), "query")
+		if i == 1 {
+			time.Sleep(2 * time.Millisecond)
+			span.SetError(errors.New("timeout"))
+		}
+		span.End()
+	}
+
+	sums := page.Summaries("query")
+	if len(sums) != 1 {
+		t.Fatalf("got %d summaries", len(sums))
+	}
+	s := sums[0]
+	if s.InFlight != 1 || s.Count != 3 || s.Errors != 1 {
+		t.Fatalf("summary = %+v", s)
+	}
+	if len(s.Recent) != 2 || len(s.Slowest) != 2 || len(s.Errored) != 1 {
+		t.Fatalf("samples: %d recent, %d slowest, %d errored", len(s.Recent), len(s.Slowest), len(s.Errored))
+	}
+	if !s.Slowest[0].IsError() || s.Slowest[0].DurationVal < s.Slowest[1].DurationVal {
+		t.Fatal("slowest spans not sorted")
+	}
+	var total uint64
+	for _, b := range s.Latency {
+		total += b.Count
+	}
+	if total != 3 {
+		t.Fatalf("latency buckets hold %d spans", total)
+	}
+
+	rec := httptest.NewRecorder()
+	page.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/spans?format=json&name=query", nil))
+	var got []struct {
+		Name    string
+		Errored []json.RawMessage
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || len(got[0].Errored) != 1 {
+		t.Fatalf("json = %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	page.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/spans?name=query", nil))
+	if body := rec.Body.String(); !strings.Contains(body, "<h2>query</h2>") || !strings.Contains(body, "timeout") {
+		t.Fatalf("html = %s", body)
+	}
+}
@@ -0,0 +1,333 @@
+package tracepkg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/thnthien/great-deku/trace"
+)
+
+// ChromeTraceExporter writes spans in the Chrome Trace Event Format, which
+// chrome://tracing and Perfetto open. Every span is a complete event whose
+// pid is the process.pid of its resource and whose tid is derived from its
+// trace ID, so the spans of a trace are shown on the same row. Its args hold
+// the attributes, the IDs and the error description of the span.
+//
+// Spans nest on their row only when they are contained in one another, so
+// spans of a trace running concurrently, such as parallel sibling calls,
+// overlap and are not drawn correctly. Their timing is still shown in the
+// args and the selection details.
+//
+// Events are buffered until ForceFlush or Shutdown, which the TracerProvider
+// calls. They use the JSON array form of the format, so the output is readable
+// after ForceFlush even though the array is only terminated by Shutdown.
+type ChromeTraceExporter struct {
+	mu        sync.Mutex
+	w         *bufio.Writer
+	closer    io.Closer
+	started   bool
+	processes map[int64]bool
+	err       error
+}
+
+// NewChromeTraceExporter returns an exporter writing to w.
+func NewChromeTraceExporter(w io.Writer) *ChromeTraceExporter {
+	return &ChromeTraceExporter{
+		w:         bufio.NewWriter(w),
+		processes: make(map[int64]bool),
+	}
+}
+
+// NewChromeTraceFileExporter returns an exporter writing to a new file at
+// path. The file is closed by Shutdown.
+func NewChromeTraceFileExporter(path string) (*ChromeTraceExporter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	e := NewChromeTraceExporter(f)
+	e.closer = f
+	return e, nil
+}
+
+// Start registers the exporter as a global exporter.
+func (e *ChromeTraceExporter) Start() {
+	RegisterExporter(e)
+}
+
+// ExportSpan writes sd. Write errors are returned by ForceFlush and Shutdown.
+func (e *ChromeTraceExporter) ExportSpan(sd *SpanData) {
+	e.mu.Lock()
+	e.writeLocked(chromeEventsFromData(sd, e.processes))
+	e.mu.Unlock()
+}
+
+// ExportSpans writes spans, so that the exporter can be used behind a
+// BatchProcessor.
+func (e *ChromeTraceExporter) ExportSpans(_ context.Context, spans []*SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, sd := range spans {
+		e.writeLocked(chromeEventsFromData(sd, e.processes))
+	}
+	if e.err == nil {
+		e.err = e.w.Flush()
+	}
+	return e.err
+}
+
+// ForceFlush writes the buffered events.
+func (e *ChromeTraceExporter) ForceFlush(context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err == nil {
+		e.err = e.w.Flush()
+	}
+	return e.err
+}
+
+// Shutdown terminates the JSON array and closes the file of
+// NewChromeTraceFileExporter.
+func (e *ChromeTraceExporter) Shutdown(context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err == nil {
+		if !e.started {
+			_, e.err = e.w.WriteString("[")
+		}
+		if e.err == nil {
+			_, e.err = e.w.WriteString("]\n")
+		}
+		if e.err == nil {
+			e.err = e.w.Flush()
+		}
+	}
+	if e.closer != nil {
+		if err := e.closer.Close(); err != nil && e.err == nil {
+			e.err = err
+		}
+		e.closer = nil
+	}
+	return e.err
+}
+
+func (e *ChromeTraceExporter) writeLocked(events []chromeEvent) {
+	for _, ev := range events {
+		if e.err != nil {
+			return
+		}
+		b, err := json.Marshal(ev)
+		if err != nil {
+			e.err = err
+			return
+		}
+		sep := ",\n"
+		if !e.started {
+			sep = "[\n"
+			e.started = true
+		}
+		if _, e.err = e.w.WriteString(sep); e.err == nil {
+			_, e.err = e.w.Write(b)
+		}
+	}
+}
+
+type chromeEvent struct {
+	Name     string                 `json:"name"`
+	Category string                 `json:"cat,omitempty"`
+	Phase    string                 `json:"ph"`
+	TS       float64                `json:"ts"`
+	Dur      float64                `json:"dur,omitempty"`
+	PID      int64                  `json:"pid"`
+	TID      int64                  `json:"tid"`
+	Args     map[string]interface{} `json:"args,omitempty"`
+}
+
+// chromeSpan holds the fields of a span needed by the Chrome format, as
+// found in SpanData or in its JSON form.
+type chromeSpan struct {
+	Name         string
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	SpanKind     string
+	StartTime    time.Time
+	Duration     time.Duration
+	Attributes   map[string]interface{}
+	Error        string
+	Resource     *Resource
+}
+
+func chromeEventsFromData(sd *SpanData, processes map[int64]bool) []chromeEvent {
+	return chromeEvents(chromeSpan{
+		Name:         sd.Name,
+		TraceID:      string(sd.TraceID),
+		SpanID:       string(sd.SpanID),
+		ParentSpanID: string(sd.ParentSpanID),
+		SpanKind:     sd.SpanKind.String(),
+		StartTime:    sd.StartTime,
+		Duration:     sd.EndTime.Sub(sd.StartTime),
+		Attributes:   sd.Attributes,
+		Error:        chromeError(sd.status()),
+		Resource:     sd.Resource,
+	}, processes)
+}
+
+func chromeError(st Status) string {
+	if st.Code != trace.StatusCodeError {
+		return ""
+	}
+	if st.Description == "" {
+		return "error"
+	}
+	return st.Description
+}
+
+// chromeEvents returns the complete event of s, preceded by a metadata event
+// naming its process the first time the process is seen.
+func chromeEvents(s chromeSpan, processes map[int64]bool) []chromeEvent {
+	var pid int64 = 1
+	serviceName := ""
+	if s.Resource != nil {
+		switch v := s.Resource.Attributes[ProcessPIDKey].(type) {
+		case int64:
+			pid = v
+		case float64:
+			pid = int64(v)
+		}
+		serviceName = s.Resource.ServiceName()
+	}
+
+	var events []chromeEvent
+	if !processes[pid] {
+		processes[pid] = true
+		if serviceName != "" {
+			events = append(events, chromeEvent{
+				Name:  "process_name",
+				Phase: "M",
+				PID:   pid,
+				Args:  map[string]interface{}{"name": serviceName},
+			})
+		}
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(s.TraceID))
+	args := make(map[string]interface{}, len(s.Attributes)+4)
+	for k, v := range s.Attributes {
+		args[k] = v
+	}
+	args["trace_id"] = s.TraceID
+	args["span_id"] = s.SpanID
+	if s.ParentSpanID != "" {
+		args["parent_span_id"] = s.ParentSpanID
+	}
+	if s.Error != "" {
+		args["error"] = s.Error
+	}
+	return append(events, chromeEvent{
+		Name:     s.Name,
+		Category: strings.ToLower(s.SpanKind),
+		Phase:    "X",
+		TS:       float64(s.StartTime.UnixNano()) / 1e3,
+		Dur:      float64(s.Duration.Nanoseconds()) / 1e3,
+		PID:      pid,
+		TID:      int64(h.Sum32() & 0x7fffffff),
+		Args:     args,
+	})
+}
+
+// ConvertLogToChromeTrace reads the log lines written by a LogExporter from r
+// and writes the spans they hold to w in the Chrome Trace Event Format. Lines
+// may be written by the console or the JSON encoder of the logger; lines
+// holding no span are skipped.
+func ConvertLogToChromeTrace(r io.Reader, w io.Writer) error {
+	e := NewChromeTraceExporter(w)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		s, ok := spanFromLogLine(sc.Bytes())
+		if !ok {
+			continue
+		}
+		e.writeLocked(chromeEvents(s, e.processes))
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return e.Shutdown(context.Background())
+}
+
+// loggedSpan is the JSON form of SpanData written by a LogExporter.
+type loggedSpan struct {
+	Name         string
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	SpanKind     string
+	StartTime    time.Time
+	EndTime      time.Time
+	Attributes   map[string]interface{}
+	Status       struct {
+		Code        string
+		Description string
+	}
+	Resource *Resource
+}
+
+func spanFromLogLine(line []byte) (chromeSpan, bool) {
+	// The JSON encoder of the logger holds the span as a string message.
+	var entry map[string]interface{}
+	if json.Unmarshal(line, &entry) == nil {
+		for _, k := range []string{"msg", "message", "M"} {
+			if msg, ok := entry[k].(string); ok {
+				if s, ok := spanFromJSON([]byte(msg)); ok {
+					return s, true
+				}
+			}
+		}
+	}
+	// The console encoder writes it raw after the level and caller.
+	for i := bytes.IndexByte(line, '{'); i >= 0; {
+		if s, ok := spanFromJSON(line[i:]); ok {
+			return s, true
+		}
+		j := bytes.IndexByte(line[i+1:], '{')
+		if j < 0 {
+			break
+		}
+		i += j + 1
+	}
+	return chromeSpan{}, false
+}
+
+func spanFromJSON(b []byte) (chromeSpan, bool) {
+	var ls loggedSpan
+	if err := json.NewDecoder(bytes.NewReader(b)).Decode(&ls); err != nil || ls.TraceID == "" || ls.SpanID == "" {
+		return chromeSpan{}, false
+	}
+	st := Status{Description: ls.Status.Description}
+	if ls.Status.Code == trace.StatusCodeError.String() {
+		st.Code = trace.StatusCodeError
+	}
+	return chromeSpan{
+		Name:         ls.Name,
+		TraceID:      ls.TraceID,
+		SpanID:       ls.SpanID,
+		ParentSpanID: ls.ParentSpanID,
+		SpanKind:     ls.SpanKind,
+		StartTime:    ls.StartTime,
+		Duration:     ls.EndTime.Sub(ls.StartTime),
+		Attributes:   ls.Attributes,
+		Error:        chromeError(st),
+		Resource:     ls.Resource,
+	}, true
+}
@@ -0,0 +1,90 @@
+package tracepkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/thnthien/great-deku/trace"
+)
+
+type chromeTestEvent struct {
+	Name string
+	Ph   string
+	Cat  string
+	Dur  float64
+	Pid  int64
+	Tid  int64
+	Args map[string]interface{}
+}
+
+func TestChromeTraceExporter(t *testing.T) {
+	var buf bytes.Buffer
+	exp := NewChromeTraceExporter(&buf)
+	res := NewResource(trace.String(ServiceNameKey, "orders"), trace.Int64(ProcessPIDKey, 42))
+	tr := NewTracerProvider(WithExporters(exp), WithResource(res)).Tracer("")
+
+	ctx, root := tr.Start(context.Background(), "root", WithSpanKind(SpanKindServer))
+	_, child := StartSpan(ctx, "child")
+	child.SetAttribute("db.system", "mysql")
+	child.SetError(errors.New("deadlock"))
+	child.End()
+	root.End()
+	if err := exp.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []chromeTestEvent
+	if err := json.Unmarshal(buf.Bytes(), &events); err != nil {
+		t.Fatalf("%v: %s", err, buf.String())
+	}
+	if len(events) != 3 || events[0].Ph != "M" || events[0].Args["name"] != "orders" {
+		t.Fatalf("events = %+v", events)
+	}
+	c, r := events[1], events[2]
+	if c.Ph != "X" || c.Pid != 42 || c.Tid != r.Tid || c.Args["db.system"] != "mysql" || c.Args["error"] != "deadlock" {
+		t.Fatalf("child = %+v", c)
+	}
+	if r.Cat != "server" || c.Args["parent_span_id"] != r.Args["span_id"] {
+		t.Fatalf("root = %+v", r)
+	}
+}
+
+func TestConvertLogToChromeTrace(t *testing.T) {
+	_, span := NewTracerProvider().Tracer("").Start(context.Background(), "logged")
+	span.SetAttribute("k", "v")
+	span.SetError(errors.New("boom"))
+	span.End()
+	sd := span.(*Span).data
+	b, _ := json.Marshal(sd)
+	jsonLine, _ := json.Marshal(map[string]string{"level": "debug", "msg": string(b)})
+
+	in := strings.Join([]string{
+		"2024-01-01T00:00:00.000Z\tDEBUG\tpkg/export_logs.go:29\t" + string(b),
+		"not a span {\"hello\": 1}",
+		string(jsonLine),
+	}, "\n")
+	var out bytes.Buffer
+	if err := ConvertLogToChromeTrace(strings.NewReader(in), &out); err != nil {
+		t.Fatal(err)
+	}
+	var events []chromeTestEvent
+	if err := json.Unmarshal(out.Bytes(), &events); err != nil {
+		t.Fatalf("%v: %s", err, out.String())
+	}
+	var spans int
+	for _, ev := range events {
+		if ev.Ph == "X" {
+			spans++
+			if ev.Name != "logged" || ev.Args["k"] != "v" || ev.Args["error"] != "boom" || ev.Args["trace_id"] != span.GetTraceID() {
+				t.Fatalf("event = %+v", ev)
+			}
+		}
+	}
+	if spans != 2 {
+		t.Fatalf("got %d spans, want 2", spans)
+	}
+}
@@ -0,0 +1,74 @@
+package tracepkg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// httpSender posts encoded span batches to a collector, retrying transient
+// failures with exponential backoff.
+type httpSender struct {
+	client     *http.Client
+	url        string
+	headers    map[string]string
+	timeout    time.Duration
+	maxRetries int
+	backoff    time.Duration
+}
+
+func (s *httpSender) send(ctx context.Context, contentType string, body []byte) error {
+	var err error
+	backoff := s.backoff
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		retry, err = s.post(ctx, contentType, body)
+		if err == nil || !retry || attempt >= s.maxRetries {
+			return err
+		}
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+		backoff *= 2
+	}
+}
+
+func (s *httpSender) post(ctx context.Context, contentType string, body []byte) (retry bool, err error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	_ = resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	err = fmt.Errorf("export to %s failed: %s", s.url, resp.Status)
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true, err
+	}
+	return false, err
+}
@@ -1,6 +1,7 @@
 package tracepkg
 
 import (
+	"context"
 	"encoding/json"
 
 	"github.com/thnthien/great-deku/l"
@@ -11,9 +12,8 @@ type LogExporter struct {
 }
 
 func (e *LogExporter) ExportSpan(sd *SpanData) {
-	//go func() {
 	str, _ := json.Marshal(sd)
-	if sd.Error != nil {
+	if sd.IsError() {
 		e.ll.Error(string(str))
 		return
 	}
@@ -27,7 +27,15 @@ func (e *LogExporter) ExportSpan(sd *SpanData) {
 	}
 
 	e.ll.Debug(string(str))
-	//}()
+}
+
+// ExportSpans logs every span, so that a LogExporter can be used behind a
+// BatchProcessor.
+func (e *LogExporter) ExportSpans(_ context.Context, spans []*SpanData) error {
+	for _, sd := range spans {
+		e.ExportSpan(sd)
+	}
+	return nil
 }
 
 func (e *LogExporter) Start() {
@@ -0,0 +1,301 @@
+package tracepkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultOTLPEndpoint = "http://localhost:4318/v1/traces"
+
+	instrumentationName = "github.com/thnthien/great-deku/trace"
+)
+
+// OTLPExporter sends spans to an OpenTelemetry collector using OTLP/HTTP with
+// the JSON encoding. It is a BatchExporter and is meant to be used behind a
+// BatchProcessor.
+type OTLPExporter struct {
+	sender *httpSender
+}
+
+// OTLPOption configures an OTLPExporter.
+type OTLPOption func(e *OTLPExporter)
+
+// WithOTLPEndpoint sets the collector URL. The default is DefaultOTLPEndpoint.
+func WithOTLPEndpoint(url string) OTLPOption {
+	return func(e *OTLPExporter) {
+		e.sender.url = url
+	}
+}
+
+// WithOTLPHeaders sets extra headers sent with every request.
+func WithOTLPHeaders(headers map[string]string) OTLPOption {
+	return func(e *OTLPExporter) {
+		e.sender.headers = headers
+	}
+}
+
+// WithOTLPTimeout sets the timeout of a single request. The default is 10s.
+func WithOTLPTimeout(d time.Duration) OTLPOption {
+	return func(e *OTLPExporter) {
+		e.sender.timeout = d
+	}
+}
+
+// WithOTLPRetry sets how many times a failed request is retried and the
+// initial backoff, which doubles on every attempt. The default is 3 retries
+// starting at 100ms.
+func WithOTLPRetry(maxRetries int, backoff time.Duration) OTLPOption {
+	return func(e *OTLPExporter) {
+		e.sender.maxRetries = maxRetries
+		e.sender.backoff = backoff
+	}
+}
+
+// WithOTLPHTTPClient sets the client used to send requests.
+func WithOTLPHTTPClient(c *http.Client) OTLPOption {
+	return func(e *OTLPExporter) {
+		e.sender.client = c
+	}
+}
+
+func NewOTLPExporter(opts ...OTLPOption) *OTLPExporter {
+	e := &OTLPExporter{
+		sender: &httpSender{
+			client:     http.DefaultClient,
+			url:        DefaultOTLPEndpoint,
+			timeout:    10 * time.Second,
+			maxRetries: 3,
+			backoff:    100 * time.Millisecond,
+		},
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// ExportSpans implements BatchExporter.
+func (e *OTLPExporter) ExportSpans(ctx context.Context, spans []*SpanData) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(otlpRequest(spans))
+	if err != nil {
+		return err
+	}
+	return e.sender.send(ctx, "application/json", body)
+}
+
+type otlpTraceRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID                string         `json:"traceId"`
+	SpanID                 string         `json:"spanId"`
+	TraceState             string         `json:"traceState,omitempty"`
+	ParentSpanID           string         `json:"parentSpanId,omitempty"`
+	Name                   string         `json:"name"`
+	Kind                   int            `json:"kind"`
+	StartTimeUnixNano      string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano        string         `json:"endTimeUnixNano"`
+	Attributes             []otlpKeyValue `json:"attributes,omitempty"`
+	DroppedAttributesCount int            `json:"droppedAttributesCount,omitempty"`
+	Events                 []otlpEvent    `json:"events,omitempty"`
+	DroppedEventsCount     int            `json:"droppedEventsCount,omitempty"`
+	Links                  []otlpLink     `json:"links,omitempty"`
+	DroppedLinksCount      int            `json:"droppedLinksCount,omitempty"`
+	Status                 otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano           string         `json:"timeUnixNano"`
+	Name                   string         `json:"name"`
+	Attributes             []otlpKeyValue `json:"attributes,omitempty"`
+	DroppedAttributesCount int            `json:"droppedAttributesCount,omitempty"`
+}
+
+type otlpLink struct {
+	TraceID                string         `json:"traceId"`
+	SpanID                 string         `json:"spanId"`
+	Attributes             []otlpKeyValue `json:"attributes,omitempty"`
+	DroppedAttributesCount int            `json:"droppedAttributesCount,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string         `json:"stringValue,omitempty"`
+	BoolValue   *bool           `json:"boolValue,omitempty"`
+	IntValue    *string         `json:"intValue,omitempty"`
+	DoubleValue *float64        `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpAnyValue `json:"values"`
+}
+
+func otlpRequest(spans []*SpanData) otlpTraceRequest {
+	var (
+		req     otlpTraceRequest
+		indexes = make(map[*Resource]int)
+	)
+	for _, sd := range spans {
+		i, ok := indexes[sd.Resource]
+		if !ok {
+			i = len(req.ResourceSpans)
+			indexes[sd.Resource] = i
+			rs := otlpResourceSpans{
+				ScopeSpans: []otlpScopeSpans{{
+					Scope: otlpScope{Name: instrumentationName},
+				}},
+			}
+			if sd.Resource != nil {
+				rs.Resource.Attributes = otlpAttributes(sd.Resource.Attributes)
+			}
+			req.ResourceSpans = append(req.ResourceSpans, rs)
+		}
+		scope := &req.ResourceSpans[i].ScopeSpans[0]
+		scope.Spans = append(scope.Spans, otlpSpanFromData(sd))
+	}
+	return req
+}
+
+func otlpSpanFromData(sd *SpanData) otlpSpan {
+	s := otlpSpan{
+		TraceID:                string(sd.TraceID),
+		SpanID:                 string(sd.SpanID),
+		TraceState:             sd.Tracestate,
+		ParentSpanID:           string(sd.ParentSpanID),
+		Name:                   sd.Name,
+		Kind:                   int(sd.SpanKind),
+		StartTimeUnixNano:      strconv.FormatInt(sd.StartTime.UnixNano(), 10),
+		EndTimeUnixNano:        strconv.FormatInt(sd.EndTime.UnixNano(), 10),
+		Attributes:             otlpAttributes(sd.Attributes),
+		DroppedAttributesCount: sd.DroppedAttributeCount,
+		DroppedEventsCount:     sd.DroppedEventCount,
+		DroppedLinksCount:      sd.DroppedLinkCount,
+	}
+	// OTLP has no unspecified kind
+	if sd.SpanKind == SpanKindUnspecified {
+		s.Kind = int(SpanKindInternal)
+	}
+	// trace.StatusCode uses the OTLP numbering
+	st := sd.status()
+	s.Status = otlpStatus{Code: int(st.Code), Message: st.Description}
+	s.Attributes = append(s.Attributes, otlpKeyValue{
+		Key:   "span.child_count",
+		Value: otlpValue(int64(sd.ChildSpanCount)),
+	})
+	for _, ev := range sd.Events {
+		s.Events = append(s.Events, otlpEvent{
+			TimeUnixNano:           strconv.FormatInt(ev.Time.UnixNano(), 10),
+			Name:                   ev.Name,
+			Attributes:             otlpAttributes(ev.Attributes),
+			DroppedAttributesCount: ev.DroppedAttributeCount,
+		})
+	}
+	for _, l := range sd.Links {
+		s.Links = append(s.Links, otlpLink{
+			TraceID:                string(l.TraceID),
+			SpanID:                 string(l.SpanID),
+			Attributes:             otlpAttributes(l.Attributes),
+			DroppedAttributesCount: l.DroppedAttributeCount,
+		})
+	}
+	return s
+}
+
+func otlpAttributes(attrs map[string]interface{}) []otlpKeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]otlpKeyValue, 0, len(attrs))
+	for _, k := range keys {
+		out = append(out, otlpKeyValue{Key: k, Value: otlpValue(attrs[k])})
+	}
+	return out
+}
+
+func otlpValue(v interface{}) otlpAnyValue {
+	switch v := v.(type) {
+	case string:
+		return otlpAnyValue{StringValue: &v}
+	case bool:
+		return otlpAnyValue{BoolValue: &v}
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32:
+		i := strconv.FormatInt(reflect.ValueOf(v).Convert(reflect.TypeOf(int64(0))).Int(), 10)
+		return otlpAnyValue{IntValue: &i}
+	case uint64:
+		i := strconv.FormatUint(v, 10)
+		return otlpAnyValue{IntValue: &i}
+	case float32:
+		f := float64(v)
+		return otlpAnyValue{DoubleValue: &f}
+	case float64:
+		return otlpAnyValue{DoubleValue: &v}
+	case fmt.Stringer:
+		str := v.String()
+		return otlpAnyValue{StringValue: &str}
+	case error:
+		str := v.Error()
+		return otlpAnyValue{StringValue: &str}
+	case nil:
+		str := ""
+		return otlpAnyValue{StringValue: &str}
+	}
+
+	rv := reflect.ValueOf(v)
+	if (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && rv.Type().Elem().Kind() != reflect.Uint8 {
+		values := make([]otlpAnyValue, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			values = append(values, otlpValue(rv.Index(i).Interface()))
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	}
+	b, err := json.Marshal(v)
+	str := string(b)
+	if err != nil {
+		str = fmt.Sprint(v)
+	}
+	return otlpAnyValue{StringValue: &str}
+}
@@ -0,0 +1,95 @@
+package tracepkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/thnthien/great-deku/trace"
+)
+
+func TestOTLPExporter(t *testing.T) {
+	var (
+		calls int32
+		got   otlpTraceRequest
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("Content-Type") != "application/json" || r.Header.Get("X-Token") != "secret" {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer collector.Close()
+
+	_, parent := StartSpan(context.Background(), "parent")
+	parent.SetAttribute("user", "alice")
+	parent.SetAttribute("count", 3)
+	parent.SetAttribute("tags", []string{"a", "b"})
+	parent.SetError(errors.New("boom"))
+	parent.End()
+
+	exp := NewOTLPExporter(
+		WithOTLPEndpoint(collector.URL),
+		WithOTLPHeaders(map[string]string{"X-Token": "secret"}),
+		WithOTLPRetry(2, time.Millisecond),
+	)
+	if err := exp.ExportSpans(context.Background(), []*SpanData{parent.GetSpanData().(*SpanData)}); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("collector called %d times, want 2", n)
+	}
+
+	spans := got.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans", len(spans))
+	}
+	s := spans[0]
+	if s.TraceID != parent.GetTraceID() || s.SpanID != parent.GetSpanID() || s.Name != "parent" {
+		t.Fatalf("unexpected span %+v", s)
+	}
+	if s.Status.Code != int(trace.StatusCodeError) || s.Status.Message != "boom" {
+		t.Fatalf("status = %+v", s.Status)
+	}
+	attrs := map[string]otlpAnyValue{}
+	for _, kv := range s.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	if v := attrs["user"].StringValue; v == nil || *v != "alice" {
+		t.Fatalf("user attribute = %+v", attrs["user"])
+	}
+	if v := attrs["count"].IntValue; v == nil || *v != "3" {
+		t.Fatalf("count attribute = %+v", attrs["count"])
+	}
+	if v := attrs["tags"].ArrayValue; v == nil || len(v.Values) != 2 {
+		t.Fatalf("tags attribute = %+v", attrs["tags"])
+	}
+}
+
+func TestOTLPExporter_NonRetryable(t *testing.T) {
+	var calls int32
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer collector.Close()
+
+	exp := NewOTLPExporter(WithOTLPEndpoint(collector.URL), WithOTLPRetry(3, time.Millisecond))
+	if err := exp.ExportSpans(context.Background(), []*SpanData{{Name: "s"}}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("collector called %d times, want 1", n)
+	}
+}
@@ -0,0 +1,218 @@
+package tracepkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultZipkinEndpoint = "http://localhost:9411/api/v2/spans"
+
+// ZipkinExporter sends spans to a Zipkin collector using the v2 JSON API. It is
+// a BatchExporter and is meant to be used behind a BatchProcessor, so that a
+// whole batch is sent in a single request.
+type ZipkinExporter struct {
+	sender      *httpSender
+	serviceName string
+}
+
+// ZipkinOption configures a ZipkinExporter.
+type ZipkinOption func(e *ZipkinExporter)
+
+// WithZipkinEndpoint sets the collector URL. The default is
+// DefaultZipkinEndpoint.
+func WithZipkinEndpoint(url string) ZipkinOption {
+	return func(e *ZipkinExporter) {
+		e.sender.url = url
+	}
+}
+
+// WithZipkinServiceName sets the service name of the local endpoint. It
+// defaults to the service name of the span resource.
+func WithZipkinServiceName(name string) ZipkinOption {
+	return func(e *ZipkinExporter) {
+		e.serviceName = name
+	}
+}
+
+// WithZipkinTimeout sets the timeout of a single request. The default is 10s.
+func WithZipkinTimeout(d time.Duration) ZipkinOption {
+	return func(e *ZipkinExporter) {
+		e.sender.timeout = d
+	}
+}
+
+// WithZipkinRetry sets how many times a failed request is retried and the
+// initial backoff, which doubles on every attempt. The default is 3 retries
+// starting at 100ms.
+func WithZipkinRetry(maxRetries int, backoff time.Duration) ZipkinOption {
+	return func(e *ZipkinExporter) {
+		e.sender.maxRetries = maxRetries
+		e.sender.backoff = backoff
+	}
+}
+
+// WithZipkinHTTPClient sets the client used to send requests.
+func WithZipkinHTTPClient(c *http.Client) ZipkinOption {
+	return func(e *ZipkinExporter) {
+		e.sender.client = c
+	}
+}
+
+func NewZipkinExporter(opts ...ZipkinOption) *ZipkinExporter {
+	e := &ZipkinExporter{
+		sender: &httpSender{
+			client:     http.DefaultClient,
+			url:        DefaultZipkinEndpoint,
+			timeout:    10 * time.Second,
+			maxRetries: 3,
+			backoff:    100 * time.Millisecond,
+		},
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// ExportSpans implements BatchExporter.
+func (e *ZipkinExporter) ExportSpans(ctx context.Context, spans []*SpanData) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	out := make([]zipkinSpan, 0, len(spans))
+	for _, sd := range spans {
+		out = append(out, e.zipkinSpanFromData(sd))
+	}
+	body, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	return e.sender.send(ctx, "application/json", body)
+}
+
+type zipkinSpan struct {
+	TraceID       string             `json:"traceId"`
+	ID            string             `json:"id"`
+	ParentID      string             `json:"parentId,omitempty"`
+	Name          string             `json:"name"`
+	Kind          string             `json:"kind,omitempty"`
+	Timestamp     int64              `json:"timestamp"`
+	Duration      int64              `json:"duration"`
+	LocalEndpoint *zipkinEndpoint    `json:"localEndpoint,omitempty"`
+	Annotations   []zipkinAnnotation `json:"annotations,omitempty"`
+	Tags          map[string]string  `json:"tags,omitempty"`
+}
+
+type zipkinAnnotation struct {
+	Timestamp int64  `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName,omitempty"`
+}
+
+func (e *ZipkinExporter) zipkinSpanFromData(sd *SpanData) zipkinSpan {
+	s := zipkinSpan{
+		TraceID:   string(sd.TraceID),
+		ID:        string(sd.SpanID),
+		ParentID:  string(sd.ParentSpanID),
+		Name:      sd.Name,
+		Kind:      zipkinKind(sd.SpanKind),
+		Timestamp: sd.StartTime.UnixNano() / int64(time.Microsecond),
+		Duration:  sd.EndTime.Sub(sd.StartTime).Microseconds(),
+	}
+	serviceName := e.serviceName
+	if serviceName == "" {
+		serviceName = sd.Resource.ServiceName()
+	}
+	if serviceName != "" {
+		s.LocalEndpoint = &zipkinEndpoint{ServiceName: serviceName}
+	}
+	// zipkin rejects zero durations
+	if s.Duration < 1 {
+		s.Duration = 1
+	}
+	if len(sd.Attributes) > 0 || sd.IsError() || len(sd.Links) > 0 {
+		s.Tags = make(map[string]string, len(sd.Attributes)+2)
+	}
+	for k, v := range sd.Attributes {
+		s.Tags[k] = attributeString(v)
+	}
+	if sd.IsError() {
+		s.Tags["error"] = sd.status().Description
+	}
+	if len(sd.Links) > 0 {
+		// zipkin has no links, keep them as "traceId-spanId" pairs
+		links := make([]string, 0, len(sd.Links))
+		for _, l := range sd.Links {
+			links = append(links, string(l.TraceID)+"-"+string(l.SpanID))
+		}
+		s.Tags["links"] = strings.Join(links, ",")
+	}
+	for _, ev := range sd.Events {
+		s.Annotations = append(s.Annotations, zipkinAnnotation{
+			Timestamp: ev.Time.UnixNano() / int64(time.Microsecond),
+			Value:     zipkinAnnotationValue(ev),
+		})
+	}
+	return s
+}
+
+// zipkinKind returns the zipkin kind, which is empty for internal spans.
+func zipkinKind(k SpanKind) string {
+	switch k {
+	case SpanKindServer, SpanKindClient, SpanKindProducer, SpanKindConsumer:
+		return k.String()
+	}
+	return ""
+}
+
+// zipkinAnnotationValue flattens an event into "name k1=v1 k2=v2", since
+// zipkin annotations carry no attributes.
+func zipkinAnnotationValue(ev Event) string {
+	if len(ev.Attributes) == 0 {
+		return ev.Name
+	}
+	keys := make([]string, 0, len(ev.Attributes))
+	for k := range ev.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(ev.Name)
+	for _, k := range keys {
+		b.WriteString(" " + k + "=" + attributeString(ev.Attributes[k]))
+	}
+	return b.String()
+}
+
+// attributeString renders an attribute value for formats that only accept
+// strings.
+func attributeString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case fmt.Stringer:
+		return v.String()
+	case error:
+		return v.Error()
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
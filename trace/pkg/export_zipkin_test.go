@@ -0,0 +1,65 @@
+package tracepkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestZipkinExporter_Batching(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests [][]zipkinSpan
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var spans []zipkinSpan
+		if err := json.NewDecoder(r.Body).Decode(&spans); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		requests = append(requests, spans)
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer collector.Close()
+
+	exp := NewZipkinExporter(WithZipkinEndpoint(collector.URL), WithZipkinServiceName("orders"))
+	bp := NewBatchProcessor(exp, WithBatchTimeout(time.Hour))
+
+	ctx, parent := StartSpan(context.Background(), "parent")
+	_, child := StartSpan(ctx, "child")
+	child.SetAttribute("db.rows", int64(7))
+	child.SetError(errors.New("timeout"))
+	child.End()
+	parent.End()
+	bp.ExportSpan(child.GetSpanData().(*SpanData))
+	bp.ExportSpan(parent.GetSpanData().(*SpanData))
+
+	if err := bp.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(requests) != 1 || len(requests[0]) != 2 {
+		t.Fatalf("got requests %+v, want one batch of 2 spans", requests)
+	}
+	s := requests[0][0]
+	if s.Name != "child" || s.ParentID != parent.GetSpanID() || s.TraceID != parent.GetTraceID() {
+		t.Fatalf("unexpected span %+v", s)
+	}
+	if s.LocalEndpoint == nil || s.LocalEndpoint.ServiceName != "orders" {
+		t.Fatalf("local endpoint = %+v", s.LocalEndpoint)
+	}
+	if s.Tags["db.rows"] != "7" || s.Tags["error"] != "timeout" {
+		t.Fatalf("tags = %v", s.Tags)
+	}
+	if s.Duration < 1 || s.Timestamp == 0 {
+		t.Fatalf("timing = %d/%d", s.Timestamp, s.Duration)
+	}
+}
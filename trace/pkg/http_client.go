@@ -0,0 +1,89 @@
+package tracepkg
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/thnthien/great-deku/trace"
+	"github.com/thnthien/great-deku/trace/pkg/baggage"
+)
+
+type transport struct {
+	base       http.RoundTripper
+	propagator Propagator
+}
+
+// TransportOption configures the RoundTripper returned by NewTransport.
+type TransportOption func(t *transport)
+
+// WithTransportPropagator sets the propagator used to inject the span context
+// into outgoing requests.
+func WithTransportPropagator(p Propagator) TransportOption {
+	return func(t *transport) {
+		t.propagator = p
+	}
+}
+
+// NewTransport wraps base so that every outgoing request runs in a child span
+// of the span found in the request context, and carries its baggage. A nil base means
+// http.DefaultTransport.
+func NewTransport(base http.RoundTripper, opts ...TransportOption) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	t := &transport{
+		base:       base,
+		propagator: DefaultPropagator,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := StartSpan(req.Context(), "HTTP "+req.Method, WithSpanKind(SpanKindClient))
+	span.SetAttribute("http.method", req.Method)
+	span.SetAttribute("http.host", req.URL.Host)
+
+	// RoundTrippers must not modify the given request.
+	req = req.Clone(ctx)
+	if sc, ok := SpanContextFromContext(ctx); ok {
+		t.propagator.Inject(sc, HeaderCarrier(req.Header))
+	}
+	baggage.Inject(ctx, HeaderCarrier(req.Header))
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.SetError(err)
+		span.End()
+		return resp, err
+	}
+
+	span.SetAttribute("http.status_code", int64(resp.StatusCode))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetError(fmt.Errorf("http status %s", resp.Status))
+	}
+	if resp.Body == nil || resp.Body == http.NoBody {
+		span.End()
+		return resp, nil
+	}
+	resp.Body = &spanBody{ReadCloser: resp.Body, span: span}
+	return resp, nil
+}
+
+// spanBody ends the span of an outgoing request once the response body is
+// closed.
+type spanBody struct {
+	io.ReadCloser
+	span trace.ISpan
+	once sync.Once
+}
+
+func (b *spanBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.span.End)
+	return err
+}
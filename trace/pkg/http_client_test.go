@@ -0,0 +1,54 @@
+package tracepkg
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/thnthien/great-deku/trace/pkg/baggage"
+)
+
+func TestNewTransport(t *testing.T) {
+	exp := newCollectExporter(t)
+
+	var traceparent, tenant string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		tenant = baggage.Value(baggage.Extract(r.Context(), HeaderCarrier(r.Header)), "tenant")
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	ctx, parent := StartSpan(baggage.WithValue(context.Background(), "tenant", "acme"), "caller")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	client := &http.Client{Transport: NewTransport(nil)}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Header.Get("traceparent") != "" {
+		t.Fatal("original request was modified")
+	}
+	if traceparent == "" {
+		t.Fatal("traceparent not propagated")
+	}
+	if tenant != "acme" {
+		t.Fatalf("baggage tenant = %q", tenant)
+	}
+
+	if sd := exp.byName("HTTP GET"); sd != nil && sd.ParentSpanID == parent.(*Span).SpanContext().SpanID {
+		t.Fatal("span ended before the body was closed")
+	}
+	_, _ = io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	sd := exp.byName("HTTP GET")
+	if sd == nil || sd.ParentSpanID != parent.(*Span).SpanContext().SpanID {
+		t.Fatalf("client span not exported as child: %+v", sd)
+	}
+	if sd.Attributes["http.status_code"] != int64(http.StatusOK) {
+		t.Fatalf("status = %v", sd.Attributes["http.status_code"])
+	}
+}
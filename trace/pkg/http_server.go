@@ -0,0 +1,188 @@
+package tracepkg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+
+	"github.com/thnthien/great-deku/trace"
+	"github.com/thnthien/great-deku/trace/pkg/baggage"
+)
+
+// DefaultPropagator is used by the HTTP instrumentation when no propagator is
+// given. It understands W3C Trace Context first and falls back to B3.
+var DefaultPropagator Propagator = NewCompositePropagator(TraceContext{}, B3{})
+
+type handler struct {
+	next       http.Handler
+	propagator Propagator
+	routeFunc  func(r *http.Request) string
+}
+
+// HandlerOption configures the handler returned by NewHandler.
+type HandlerOption func(h *handler)
+
+// WithHandlerPropagator sets the propagator used to extract the remote parent.
+func WithHandlerPropagator(p Propagator) HandlerOption {
+	return func(h *handler) {
+		h.propagator = p
+	}
+}
+
+// WithRouteFunc sets the function that returns the route of a request, such as
+// the pattern matched by a router. The route names the span and is recorded as
+// http.route. Without it, or when it returns "", spans are named after the
+// method only, since the URL path would make span names unbounded.
+func WithRouteFunc(f func(r *http.Request) string) HandlerOption {
+	return func(h *handler) {
+		h.routeFunc = f
+	}
+}
+
+// NewHandler wraps next so that a span is started for every request. The span
+// continues the remote parent found in the request headers, and it is stored in
+// the request context for downstream handlers, along with the incoming
+// baggage.
+func NewHandler(next http.Handler, opts ...HandlerOption) http.Handler {
+	h := &handler{
+		next:       next,
+		propagator: DefaultPropagator,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var route string
+	if h.routeFunc != nil {
+		route = h.routeFunc(r)
+	}
+	name := "HTTP " + r.Method
+	if route != "" {
+		name = r.Method + " " + route
+	}
+
+	var (
+		ctx  = baggage.Extract(r.Context(), HeaderCarrier(r.Header))
+		span trace.ISpan
+	)
+	if parent, ok := h.propagator.Extract(HeaderCarrier(r.Header)); ok {
+		ctx, span = StartSpanWithRemoteParent(ctx, name, parent, WithSpanKind(SpanKindServer))
+	} else {
+		ctx, span = StartSpan(ctx, name, WithSpanKind(SpanKindServer))
+	}
+
+	span.SetAttribute("http.method", r.Method)
+	if route != "" {
+		span.SetAttribute("http.route", route)
+	}
+	span.SetAttribute("http.target", r.URL.RequestURI())
+	span.SetAttribute("net.peer.addr", r.RemoteAddr)
+
+	body := &countingReadCloser{ReadCloser: r.Body}
+	if r.Body != nil && r.Body != http.NoBody {
+		r.Body = body
+	}
+	rw := &responseWriter{ResponseWriter: w}
+
+	defer func() {
+		// The bytes read are only used for bodies of unknown length, such as
+		// chunked ones, since the handler may not read the whole body.
+		requestLength := r.ContentLength
+		if requestLength < 0 {
+			requestLength = atomic.LoadInt64(&body.n)
+		}
+		span.SetAttribute("http.request_content_length", requestLength)
+		span.SetAttribute("http.response_content_length", rw.written)
+		status := rw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if p := recover(); p != nil {
+			span.SetAttribute("http.status_code", int64(http.StatusInternalServerError))
+			span.SetError(&PanicError{Value: p, Stack: debug.Stack()})
+			span.End()
+			panic(p)
+		}
+		span.SetAttribute("http.status_code", int64(status))
+		if status >= http.StatusInternalServerError {
+			span.SetError(fmt.Errorf("http status %d %s", status, http.StatusText(status)))
+		}
+		span.End()
+	}()
+
+	h.next.ServeHTTP(rw, r.WithContext(ctx))
+}
+
+type countingReadCloser struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	atomic.AddInt64(&c.n, int64(n))
+	return n, err
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// ReadFrom keeps the io.ReaderFrom optimisation of the underlying writer.
+func (w *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := io.Copy(w.ResponseWriter, r)
+	w.written += n
+	return n, err
+}
+
+// Hijack lets handlers take over the connection, for instance to upgrade it
+// to a websocket, when the underlying writer supports it.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("tracepkg: %T does not implement http.Hijacker", w.ResponseWriter)
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+// Unwrap returns the underlying writer, for http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,148 @@
+package tracepkg
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	spancontext "github.com/thnthien/great-deku/trace/pkg/span-context"
+)
+
+type collectExporter struct {
+	mu    sync.Mutex
+	spans []*SpanData
+}
+
+func (e *collectExporter) ExportSpan(sd *SpanData) {
+	e.mu.Lock()
+	e.spans = append(e.spans, sd)
+	e.mu.Unlock()
+}
+
+func (e *collectExporter) byName(name string) *SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for i := len(e.spans) - 1; i >= 0; i-- {
+		if e.spans[i].Name == name {
+			return e.spans[i]
+		}
+	}
+	return nil
+}
+
+func newCollectExporter(t *testing.T) *collectExporter {
+	e := &collectExporter{}
+	RegisterExporter(e)
+	t.Cleanup(func() {
+		UnregisterExporter(e)
+	})
+	return e
+}
+
+func TestNewHandler(t *testing.T) {
+	exp := newCollectExporter(t)
+
+	var inHandler *Span
+	h := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inHandler, _ = spancontext.FromContext(r.Context()).(*Span)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("hello"))
+	}), WithRouteFunc(func(r *http.Request) string { return "/users/{id}" }))
+
+	req := httptest.NewRequest(http.MethodPost, "/users/1", strings.NewReader("payload"))
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if inHandler == nil {
+		t.Fatal("span not stored in request context")
+	}
+	sd := exp.byName("POST /users/{id}")
+	if sd == nil {
+		t.Fatal("span not exported")
+	}
+	if sd.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || sd.ParentSpanID != "00f067aa0ba902b7" {
+		t.Fatalf("remote parent not used: %+v", sd.SpanContext)
+	}
+	if sd.Attributes["http.status_code"] != int64(http.StatusCreated) {
+		t.Fatalf("status = %v", sd.Attributes["http.status_code"])
+	}
+	if sd.Attributes["http.request_content_length"] != int64(7) {
+		t.Fatalf("request size = %v", sd.Attributes["http.request_content_length"])
+	}
+	if sd.Attributes["http.response_content_length"] != int64(5) {
+		t.Fatalf("response size = %v", sd.Attributes["http.response_content_length"])
+	}
+	if sd.Attributes["http.route"] != "/users/{id}" || sd.Attributes["http.target"] != "/users/1" {
+		t.Fatalf("route = %v, target = %v", sd.Attributes["http.route"], sd.Attributes["http.target"])
+	}
+	if sd.Error != nil {
+		t.Fatalf("unexpected error %v", sd.Error)
+	}
+}
+
+func TestNewHandler_Errors(t *testing.T) {
+	exp := newCollectExporter(t)
+
+	h := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/panic" {
+			panic("boom")
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fail", nil))
+	sd := exp.byName("HTTP GET")
+	if sd == nil || sd.Error == nil {
+		t.Fatalf("5xx not recorded as error: %+v", sd)
+	}
+	if sd.Attributes["http.target"] != "/fail" || sd.Attributes["http.route"] != nil {
+		t.Fatalf("target = %v, route = %v", sd.Attributes["http.target"], sd.Attributes["http.route"])
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("panic not propagated")
+			}
+		}()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/panic", nil))
+	}()
+	if sd := exp.byName("HTTP GET"); sd == nil || sd.Error == nil || sd.Attributes["http.target"] != "/panic" {
+		t.Fatalf("panic not recorded as error: %+v", sd)
+	}
+}
+
+func TestNewHandler_Hijack(t *testing.T) {
+	newCollectExporter(t)
+	var unwrapped bool
+	srv := httptest.NewServer(NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, unwrapped = w.(interface{ Unwrap() http.ResponseWriter })
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		_, _ = buf.WriteString("HTTP/1.1 101 Switching Protocols\r\n\r\nhello")
+		_ = buf.Flush()
+	})))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, _ = conn.Write([]byte("GET / HTTP/1.1\r\nHost: x\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n"))
+	b, _ := io.ReadAll(conn)
+	if !strings.HasSuffix(string(b), "hello") {
+		t.Fatalf("response = %q", b)
+	}
+	if !unwrapped {
+		t.Fatal("response writer does not implement Unwrap")
+	}
+}
@@ -0,0 +1,241 @@
+package tracepkg
+
+import (
+	"bufio"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultMetricsBuckets are the upper bounds, in seconds, of the duration
+// histograms of a MetricsProcessor unless WithMetricsBuckets is given.
+var DefaultMetricsBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type metricsConfig struct {
+	namespace string
+	buckets   []float64
+	labels    []string
+	maxSeries int
+}
+
+// MetricsOption configures a MetricsProcessor.
+type MetricsOption func(c *metricsConfig)
+
+// WithMetricsNamespace sets the prefix of the metric names. The default is
+// "span".
+func WithMetricsNamespace(ns string) MetricsOption {
+	return func(c *metricsConfig) {
+		c.namespace = ns
+	}
+}
+
+// WithMetricsBuckets sets the upper bounds, in seconds, of the duration
+// histograms. The default is DefaultMetricsBuckets.
+func WithMetricsBuckets(buckets ...float64) MetricsOption {
+	return func(c *metricsConfig) {
+		c.buckets = append([]float64(nil), buckets...)
+	}
+}
+
+// WithMetricsLabels adds the values of the given span attributes as labels of
+// the metrics. Characters not allowed in label names, such as dots, are
+// replaced with underscores. Names colliding with span_name, le, another label
+// or starting with the reserved "__" prefix are prefixed with "attr_".
+func WithMetricsLabels(attributeKeys ...string) MetricsOption {
+	return func(c *metricsConfig) {
+		c.labels = append(c.labels, attributeKeys...)
+	}
+}
+
+// WithMetricsMaxSeries sets the number of label combinations tracked. Spans
+// with new combinations are ignored once it is reached. The default is 10000.
+func WithMetricsMaxSeries(n int) MetricsOption {
+	return func(c *metricsConfig) {
+		c.maxSeries = n
+	}
+}
+
+// MetricsProcessor is an Exporter aggregating the ended spans into request,
+// error and duration metrics per span name, which it serves over HTTP in the
+// Prometheus text exposition format.
+//
+// Like every exporter, it only sees sampled spans, so the counts and rates are
+// undercounted whenever a sampler other than AlwaysSample is used.
+type MetricsProcessor struct {
+	cfg        metricsConfig
+	labelNames []string
+
+	mu     sync.Mutex
+	series map[string]*metricsSeries
+}
+
+type metricsSeries struct {
+	labels  []string // span name, then the values of cfg.labels
+	calls   uint64
+	errors  uint64
+	buckets []uint64 // not cumulative
+	sum     float64
+}
+
+// NewMetricsProcessor creates a MetricsProcessor. It records nothing until it
+// is registered as an exporter, with Start or WithExporters.
+func NewMetricsProcessor(opts ...MetricsOption) *MetricsProcessor {
+	cfg := metricsConfig{
+		namespace: "span",
+		buckets:   append([]float64(nil), DefaultMetricsBuckets...),
+		maxSeries: 10000,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.maxSeries <= 0 {
+		cfg.maxSeries = 10000
+	}
+	sort.Float64s(cfg.buckets)
+
+	labelNames := []string{"span_name"}
+	used := map[string]bool{"span_name": true, "le": true}
+	for _, k := range cfg.labels {
+		name := metricName(k)
+		for used[name] || strings.HasPrefix(name, "__") {
+			name = "attr_" + name
+		}
+		used[name] = true
+		labelNames = append(labelNames, name)
+	}
+	return &MetricsProcessor{
+		cfg:        cfg,
+		labelNames: labelNames,
+		series:     make(map[string]*metricsSeries),
+	}
+}
+
+// Start registers the processor as a global exporter.
+func (m *MetricsProcessor) Start() {
+	RegisterExporter(m)
+}
+
+// ExportSpan implements Exporter.
+func (m *MetricsProcessor) ExportSpan(sd *SpanData) {
+	labels := make([]string, 0, len(m.labelNames))
+	labels = append(labels, sd.Name)
+	for _, k := range m.cfg.labels {
+		v, ok := sd.Attributes[k]
+		if !ok {
+			labels = append(labels, "")
+			continue
+		}
+		labels = append(labels, attributeString(v))
+	}
+	key := strings.Join(labels, "\xff")
+	seconds := sd.DurationVal.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.series[key]
+	if !ok {
+		if len(m.series) >= m.cfg.maxSeries {
+			return
+		}
+		s = &metricsSeries{labels: labels, buckets: make([]uint64, len(m.cfg.buckets))}
+		m.series[key] = s
+	}
+	s.calls++
+	if sd.IsError() {
+		s.errors++
+	}
+	s.sum += seconds
+	if i := sort.SearchFloat64s(m.cfg.buckets, seconds); i < len(s.buckets) {
+		s.buckets[i]++
+	}
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (m *MetricsProcessor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	m.write(bw)
+	_ = bw.Flush()
+}
+
+func (m *MetricsProcessor) write(w *bufio.Writer) {
+	m.mu.Lock()
+	series := make([]metricsSeries, 0, len(m.series))
+	for _, s := range m.series {
+		c := *s
+		c.buckets = append([]uint64(nil), s.buckets...)
+		series = append(series, c)
+	}
+	m.mu.Unlock()
+	sort.Slice(series, func(i, j int) bool {
+		return strings.Join(series[i].labels, "\xff") < strings.Join(series[j].labels, "\xff")
+	})
+
+	ns := metricName(m.cfg.namespace)
+	calls, errs, duration := ns+"_calls_total", ns+"_errors_total", ns+"_duration_seconds"
+
+	w.WriteString("# HELP " + calls + " Number of ended spans.\n# TYPE " + calls + " counter\n")
+	for _, s := range series {
+		m.writeSample(w, calls, s.labels, "", "", float64(s.calls))
+	}
+	w.WriteString("# HELP " + errs + " Number of ended spans with an error.\n# TYPE " + errs + " counter\n")
+	for _, s := range series {
+		m.writeSample(w, errs, s.labels, "", "", float64(s.errors))
+	}
+	w.WriteString("# HELP " + duration + " Duration of the ended spans.\n# TYPE " + duration + " histogram\n")
+	for _, s := range series {
+		var cumulative uint64
+		for i, b := range m.cfg.buckets {
+			cumulative += s.buckets[i]
+			m.writeSample(w, duration+"_bucket", s.labels, "le", formatFloat(b), float64(cumulative))
+		}
+		m.writeSample(w, duration+"_bucket", s.labels, "le", "+Inf", float64(s.calls))
+		m.writeSample(w, duration+"_sum", s.labels, "", "", s.sum)
+		m.writeSample(w, duration+"_count", s.labels, "", "", float64(s.calls))
+	}
+}
+
+func (m *MetricsProcessor) writeSample(w *bufio.Writer, name string, labels []string, extraName, extraValue string, v float64) {
+	w.WriteString(name)
+	w.WriteByte('{')
+	for i, l := range labels {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		w.WriteString(m.labelNames[i])
+		w.WriteString(`="`)
+		w.WriteString(escapeLabelValue(l))
+		w.WriteByte('"')
+	}
+	if extraName != "" {
+		w.WriteString(`,` + extraName + `="` + extraValue + `"`)
+	}
+	w.WriteString("} ")
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// metricName replaces the characters not allowed in Prometheus metric and
+// label names with underscores.
+func metricName(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9' {
+			continue
+		}
+		b[i] = '_'
+	}
+	return string(b)
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
@@ -0,0 +1,71 @@
+package tracepkg
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsProcessor(t *testing.T) {
+	m := NewMetricsProcessor(WithMetricsBuckets(10, 0.5), WithMetricsLabels("http.route"))
+	tr := NewTracerProvider(WithExporters(m)).Tracer("")
+
+	for i := 0; i < 3; i++ {
+		_, span := tr.Start(context.Background(), "GET")
+		span.SetAttribute("http.route", `/users/"id"`)
+		if i == 0 {
+			span.SetError(errors.New("boom"))
+		}
+		span.End()
+	}
+	_, span := tr.Start(context.Background(), "GET")
+	span.End()
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE span_calls_total counter\n",
+		`span_calls_total{span_name="GET",http_route=""} 1` + "\n",
+		`span_calls_total{span_name="GET",http_route="/users/\"id\""} 3` + "\n",
+		`span_errors_total{span_name="GET",http_route="/users/\"id\""} 1` + "\n",
+		"# TYPE span_duration_seconds histogram\n",
+		`span_duration_seconds_bucket{span_name="GET",http_route="/users/\"id\"",le="0.5"} 3` + "\n",
+		`span_duration_seconds_bucket{span_name="GET",http_route="/users/\"id\"",le="10"} 3` + "\n",
+		`span_duration_seconds_bucket{span_name="GET",http_route="/users/\"id\"",le="+Inf"} 3` + "\n",
+		`span_duration_seconds_count{span_name="GET",http_route="/users/\"id\""} 3` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in:\n%s", want, body)
+		}
+	}
+}
+
+func TestMetricsProcessor_MaxSeries(t *testing.T) {
+	m := NewMetricsProcessor(WithMetricsMaxSeries(1))
+	tr := NewTracerProvider(WithExporters(m)).Tracer("")
+	for _, name := range []string{"a", "b"} {
+		_, span := tr.Start(context.Background(), name)
+		span.End()
+	}
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if strings.Contains(rec.Body.String(), `span_name="b"`) {
+		t.Fatal("series over the limit recorded")
+	}
+}
+
+func TestMetricsProcessor_LabelCollisions(t *testing.T) {
+	m := NewMetricsProcessor(WithMetricsLabels("span.name", "le", "__meta", "route", "route"))
+	want := []string{"span_name", "attr_span_name", "attr_le", "attr___meta", "route", "attr_route"}
+	if len(m.labelNames) != len(want) {
+		t.Fatalf("labels = %v", m.labelNames)
+	}
+	for i, name := range want {
+		if m.labelNames[i] != name {
+			t.Fatalf("labels = %v, want %v", m.labelNames, want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package tracepkg
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/thnthien/great-deku/trace/pkg/id"
+	spancontext "github.com/thnthien/great-deku/trace/pkg/span-context"
+)
+
+// Carrier is the storage medium used by a Propagator, such as the headers of
+// an HTTP request.
+type Carrier interface {
+	Get(key string) string
+	Set(key, value string)
+}
+
+// HeaderCarrier adapts http.Header to the Carrier interface.
+type HeaderCarrier http.Header
+
+func (c HeaderCarrier) Get(key string) string {
+	return http.Header(c).Get(key)
+}
+
+func (c HeaderCarrier) Set(key, value string) {
+	http.Header(c).Set(key, value)
+}
+
+// MapCarrier adapts map[string]string to the Carrier interface. Keys are
+// matched case-insensitively on Get.
+type MapCarrier map[string]string
+
+func (c MapCarrier) Get(key string) string {
+	if v, ok := c[key]; ok {
+		return v
+	}
+	for k, v := range c {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+func (c MapCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+// Propagator injects a SpanContext into a carrier and extracts a remote parent
+// SpanContext from it.
+type Propagator interface {
+	Inject(sc spancontext.SpanContext, carrier Carrier)
+	Extract(carrier Carrier) (spancontext.SpanContext, bool)
+}
+
+const (
+	traceparentHeader = "traceparent"
+	tracestateHeader  = "tracestate"
+
+	traceContextVersion = "00"
+	maxTracestateLen    = 512
+)
+
+// TraceContext propagates SpanContext using the W3C Trace Context headers
+// traceparent and tracestate.
+type TraceContext struct{}
+
+// Inject writes sc to the traceparent and tracestate headers.
+func (TraceContext) Inject(sc spancontext.SpanContext, carrier Carrier) {
+	if !sc.IsValid() {
+		return
+	}
+	flags := "00"
+	if sc.IsSampled() {
+		flags = "01"
+	}
+	carrier.Set(traceparentHeader, fmt.Sprintf("%s-%s-%s-%s", traceContextVersion, sc.TraceID, sc.SpanID, flags))
+	if sc.Tracestate != "" {
+		carrier.Set(tracestateHeader, sc.Tracestate)
+	}
+}
+
+// Extract parses the traceparent and tracestate headers. It returns false if
+// traceparent is missing or malformed.
+func (TraceContext) Extract(carrier Carrier) (spancontext.SpanContext, bool) {
+	h := strings.TrimSpace(carrier.Get(traceparentHeader))
+	if h == "" {
+		return spancontext.SpanContext{}, false
+	}
+	parts := strings.Split(h, "-")
+	if len(parts) < 4 {
+		return spancontext.SpanContext{}, false
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if len(version) != 2 || !isLowerHex(version) || version == "ff" {
+		return spancontext.SpanContext{}, false
+	}
+	// Version 00 has exactly four fields; later versions may append more.
+	if version == traceContextVersion && len(parts) != 4 {
+		return spancontext.SpanContext{}, false
+	}
+	if !isValidHexID(traceID, 32) || !isValidHexID(spanID, 16) {
+		return spancontext.SpanContext{}, false
+	}
+	if len(flags) != 2 || !isLowerHex(flags) {
+		return spancontext.SpanContext{}, false
+	}
+	fb, _ := hex.DecodeString(flags)
+
+	sc := spancontext.SpanContext{
+		TraceID: id.TraceID(traceID),
+		SpanID:  id.SpanID(spanID),
+	}
+	sc.TraceOptions.SetIsSampled(fb[0]&1 == 1)
+	if ts := strings.TrimSpace(carrier.Get(tracestateHeader)); ts != "" && len(ts) <= maxTracestateLen {
+		sc.Tracestate = ts
+	}
+	return sc, true
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// isValidHexID reports whether s is a non-zero lowercase hex string of size n.
+func isValidHexID(s string, n int) bool {
+	if len(s) != n || !isLowerHex(s) {
+		return false
+	}
+	return strings.Trim(s, "0") != ""
+}
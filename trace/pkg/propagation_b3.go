@@ -0,0 +1,148 @@
+package tracepkg
+
+import (
+	"strings"
+
+	"github.com/thnthien/great-deku/trace/pkg/id"
+	spancontext "github.com/thnthien/great-deku/trace/pkg/span-context"
+)
+
+const (
+	b3TraceIDHeader      = "X-B3-TraceId"
+	b3SpanIDHeader       = "X-B3-SpanId"
+	b3ParentSpanIDHeader = "X-B3-ParentSpanId"
+	b3SampledHeader      = "X-B3-Sampled"
+	b3FlagsHeader        = "X-B3-Flags"
+	b3SingleHeader       = "b3"
+)
+
+// B3Encoding selects the headers written by B3.Inject.
+type B3Encoding uint8
+
+const (
+	// B3MultipleHeader writes the X-B3-* headers.
+	B3MultipleHeader B3Encoding = 1 << iota
+	// B3SingleHeader writes the single b3 header.
+	B3SingleHeader
+)
+
+// B3 propagates SpanContext using the Zipkin B3 headers. Extract accepts both
+// the single and the multiple header encodings, the single header taking
+// precedence.
+type B3 struct {
+	// InjectEncoding defaults to B3MultipleHeader when zero.
+	InjectEncoding B3Encoding
+}
+
+// Inject writes sc to the B3 headers selected by InjectEncoding.
+func (b B3) Inject(sc spancontext.SpanContext, carrier Carrier) {
+	if !sc.IsValid() {
+		return
+	}
+	enc := b.InjectEncoding
+	if enc == 0 {
+		enc = B3MultipleHeader
+	}
+	sampled := "0"
+	if sc.IsSampled() {
+		sampled = "1"
+	}
+	if enc&B3SingleHeader != 0 {
+		carrier.Set(b3SingleHeader, string(sc.TraceID)+"-"+string(sc.SpanID)+"-"+sampled)
+	}
+	if enc&B3MultipleHeader != 0 {
+		carrier.Set(b3TraceIDHeader, string(sc.TraceID))
+		carrier.Set(b3SpanIDHeader, string(sc.SpanID))
+		carrier.Set(b3SampledHeader, sampled)
+	}
+}
+
+// Extract parses the b3 header, or the X-B3-* headers when it is absent.
+func (b B3) Extract(carrier Carrier) (spancontext.SpanContext, bool) {
+	if h := strings.TrimSpace(carrier.Get(b3SingleHeader)); h != "" {
+		return extractB3Single(h)
+	}
+	return extractB3Multiple(carrier)
+}
+
+func extractB3Single(h string) (spancontext.SpanContext, bool) {
+	parts := strings.Split(h, "-")
+	// A lone sampling state carries no trace to continue.
+	if len(parts) < 2 || len(parts) > 4 {
+		return spancontext.SpanContext{}, false
+	}
+	var sampled string
+	if len(parts) > 2 {
+		sampled = parts[2]
+	}
+	if len(parts) == 4 && !isValidHexID(parts[3], 16) {
+		return spancontext.SpanContext{}, false
+	}
+	return newB3SpanContext(parts[0], parts[1], sampled, "")
+}
+
+func extractB3Multiple(carrier Carrier) (spancontext.SpanContext, bool) {
+	traceID := strings.TrimSpace(carrier.Get(b3TraceIDHeader))
+	spanID := strings.TrimSpace(carrier.Get(b3SpanIDHeader))
+	if traceID == "" || spanID == "" {
+		return spancontext.SpanContext{}, false
+	}
+	if p := strings.TrimSpace(carrier.Get(b3ParentSpanIDHeader)); p != "" && !isValidHexID(p, 16) {
+		return spancontext.SpanContext{}, false
+	}
+	return newB3SpanContext(traceID, spanID,
+		strings.TrimSpace(carrier.Get(b3SampledHeader)),
+		strings.TrimSpace(carrier.Get(b3FlagsHeader)))
+}
+
+func newB3SpanContext(traceID, spanID, sampled, flags string) (spancontext.SpanContext, bool) {
+	traceID = strings.ToLower(traceID)
+	spanID = strings.ToLower(spanID)
+	// 64-bit trace IDs are left-padded to the 128-bit form used by tracepkg.
+	if len(traceID) == 16 {
+		traceID = strings.Repeat("0", 16) + traceID
+	}
+	if !isValidHexID(traceID, 32) || !isValidHexID(spanID, 16) {
+		return spancontext.SpanContext{}, false
+	}
+
+	sc := spancontext.SpanContext{
+		TraceID: id.TraceID(traceID),
+		SpanID:  id.SpanID(spanID),
+	}
+	switch {
+	case flags == "1", sampled == "d":
+		// debug implies sampled
+		sc.TraceOptions.SetIsSampled(true)
+	case sampled == "1", strings.EqualFold(sampled, "true"):
+		sc.TraceOptions.SetIsSampled(true)
+	case sampled == "", sampled == "0", strings.EqualFold(sampled, "false"):
+	default:
+		return spancontext.SpanContext{}, false
+	}
+	return sc, true
+}
+
+type compositePropagator []Propagator
+
+// NewCompositePropagator returns a Propagator that writes every format on
+// Inject and returns the first successful result on Extract, trying
+// propagators in the given order.
+func NewCompositePropagator(ps ...Propagator) Propagator {
+	return compositePropagator(ps)
+}
+
+func (c compositePropagator) Inject(sc spancontext.SpanContext, carrier Carrier) {
+	for _, p := range c {
+		p.Inject(sc, carrier)
+	}
+}
+
+func (c compositePropagator) Extract(carrier Carrier) (spancontext.SpanContext, bool) {
+	for _, p := range c {
+		if sc, ok := p.Extract(carrier); ok {
+			return sc, true
+		}
+	}
+	return spancontext.SpanContext{}, false
+}
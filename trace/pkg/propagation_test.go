@@ -0,0 +1,152 @@
+package tracepkg
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	spancontext "github.com/thnthien/great-deku/trace/pkg/span-context"
+)
+
+func TestTraceContext_InjectExtract(t *testing.T) {
+	_, span := StartSpan(context.Background(), "client")
+	sc := span.(*Span).SpanContext()
+	sc.Tracestate = "vendor=value"
+
+	h := http.Header{}
+	TraceContext{}.Inject(sc, HeaderCarrier(h))
+	if h.Get("traceparent") == "" {
+		t.Fatal("traceparent header not injected")
+	}
+
+	got, ok := TraceContext{}.Extract(HeaderCarrier(h))
+	if !ok {
+		t.Fatalf("extract failed for %q", h.Get("traceparent"))
+	}
+	if got.TraceID != sc.TraceID || got.SpanID != sc.SpanID {
+		t.Fatalf("got %+v, want %+v", got, sc)
+	}
+	if !got.IsSampled() {
+		t.Fatal("sampled flag lost")
+	}
+	if got.Tracestate != "vendor=value" {
+		t.Fatalf("tracestate = %q", got.Tracestate)
+	}
+
+	_, child := StartSpanWithRemoteParent(context.Background(), "server", got)
+	cd := child.GetSpanData().(*SpanData)
+	if cd.TraceID != sc.TraceID || cd.ParentSpanID != sc.SpanID || !cd.HasRemoteParent {
+		t.Fatalf("remote parent not applied: %+v", cd)
+	}
+}
+
+func TestTraceContext_Extract(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		ok      bool
+		sampled bool
+	}{
+		{"sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"not sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		{"future version", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true, true},
+		{"empty", "", false, false},
+		{"invalid version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"zero trace id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"zero span id", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, false},
+		{"upper case", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, false},
+		{"extra field", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, false},
+		{"short span id", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902-01", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, ok := TraceContext{}.Extract(MapCarrier{"Traceparent": tt.header})
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && sc.IsSampled() != tt.sampled {
+				t.Fatalf("sampled = %v, want %v", sc.IsSampled(), tt.sampled)
+			}
+		})
+	}
+}
+
+func TestTraceContext_InjectInvalid(t *testing.T) {
+	c := MapCarrier{}
+	TraceContext{}.Inject(spancontext.SpanContext{}, c)
+	if len(c) != 0 {
+		t.Fatalf("unexpected headers %v", c)
+	}
+}
+
+func TestB3_Extract(t *testing.T) {
+	tests := []struct {
+		name    string
+		carrier MapCarrier
+		ok      bool
+		sampled bool
+		traceID string
+	}{
+		{"multi", MapCarrier{
+			"X-B3-TraceId": "4bf92f3577b34da6a3ce929d0e0e4736",
+			"X-B3-SpanId":  "00f067aa0ba902b7",
+			"X-B3-Sampled": "1",
+		}, true, true, "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"multi 64-bit", MapCarrier{
+			"x-b3-traceid": "a3ce929d0e0e4736",
+			"x-b3-spanid":  "00f067aa0ba902b7",
+		}, true, false, "0000000000000000a3ce929d0e0e4736"},
+		{"multi debug", MapCarrier{
+			"X-B3-TraceId": "4bf92f3577b34da6a3ce929d0e0e4736",
+			"X-B3-SpanId":  "00f067aa0ba902b7",
+			"X-B3-Flags":   "1",
+		}, true, true, "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"single", MapCarrier{
+			"b3": "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1-05e3ac9a4f6e3b90",
+		}, true, true, "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"single deny only", MapCarrier{"b3": "0"}, false, false, ""},
+		{"bad sampled", MapCarrier{
+			"X-B3-TraceId": "4bf92f3577b34da6a3ce929d0e0e4736",
+			"X-B3-SpanId":  "00f067aa0ba902b7",
+			"X-B3-Sampled": "yes",
+		}, false, false, ""},
+		{"missing span id", MapCarrier{"X-B3-TraceId": "4bf92f3577b34da6a3ce929d0e0e4736"}, false, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, ok := B3{}.Extract(tt.carrier)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if sc.IsSampled() != tt.sampled {
+				t.Fatalf("sampled = %v, want %v", sc.IsSampled(), tt.sampled)
+			}
+			if string(sc.TraceID) != tt.traceID {
+				t.Fatalf("trace id = %s, want %s", sc.TraceID, tt.traceID)
+			}
+		})
+	}
+}
+
+func TestCompositePropagator(t *testing.T) {
+	_, span := StartSpan(context.Background(), "client")
+	sc := span.(*Span).SpanContext()
+
+	p := NewCompositePropagator(TraceContext{}, B3{InjectEncoding: B3SingleHeader | B3MultipleHeader})
+	h := http.Header{}
+	p.Inject(sc, HeaderCarrier(h))
+	for _, k := range []string{"traceparent", "b3", "X-B3-TraceId", "X-B3-SpanId", "X-B3-Sampled"} {
+		if h.Get(k) == "" {
+			t.Fatalf("header %s not injected", k)
+		}
+	}
+
+	h.Del("traceparent")
+	got, ok := p.Extract(HeaderCarrier(h))
+	if !ok || got.TraceID != sc.TraceID || got.SpanID != sc.SpanID {
+		t.Fatalf("fallback extract = %+v, %v", got, ok)
+	}
+}
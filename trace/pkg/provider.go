@@ -0,0 +1,292 @@
+package tracepkg
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/thnthien/great-deku/trace"
+	"github.com/thnthien/great-deku/trace/pkg/baggage"
+	"github.com/thnthien/great-deku/trace/pkg/id"
+	spancontext "github.com/thnthien/great-deku/trace/pkg/span-context"
+)
+
+// TracerProvider owns the configuration shared by its tracers: exporters,
+// sampler, ID generator and resource. Providers are independent from each
+// other, so libraries and tests can use their own without touching the
+// default one used by StartSpan.
+type TracerProvider struct {
+	exporterMu sync.Mutex
+	exporters  atomic.Value // exportersMap
+	sampler    atomic.Value // Sampler
+	idGen      id.IDGenerator
+	resource   *Resource
+	limits     SpanLimits
+	// baggageKeys are copied from the baggage to the attributes of new spans.
+	baggageKeys []string
+	isShutdown  int32
+}
+
+// ProviderOption configures a TracerProvider.
+type ProviderOption func(p *TracerProvider)
+
+// WithProviderSampler sets the sampler consulted for root spans and spans with
+// a remote parent. The default is AlwaysSample.
+func WithProviderSampler(s Sampler) ProviderOption {
+	return func(p *TracerProvider) {
+		p.SetSampler(s)
+	}
+}
+
+// WithIDGenerator sets the generator of trace and span IDs. The default is
+// id.TraceGen.
+func WithIDGenerator(g id.IDGenerator) ProviderOption {
+	return func(p *TracerProvider) {
+		p.idGen = g
+	}
+}
+
+// WithResource sets the resource attached to every span of the provider. The
+// default is DetectResource().
+func WithResource(r *Resource) ProviderOption {
+	return func(p *TracerProvider) {
+		p.resource = r
+	}
+}
+
+// WithSpanLimits sets the limits of the spans of the provider. The zero fields
+// of l keep their value of DefaultSpanLimits().
+func WithSpanLimits(l SpanLimits) ProviderOption {
+	return func(p *TracerProvider) {
+		if l.AttributeCountLimit != 0 {
+			p.limits.AttributeCountLimit = l.AttributeCountLimit
+		}
+		if l.AttributeValueLengthLimit != 0 {
+			p.limits.AttributeValueLengthLimit = l.AttributeValueLengthLimit
+		}
+		if l.EventCountLimit != 0 {
+			p.limits.EventCountLimit = l.EventCountLimit
+		}
+		if l.LinkCountLimit != 0 {
+			p.limits.LinkCountLimit = l.LinkCountLimit
+		}
+	}
+}
+
+// WithBaggageAttributes copies the given baggage keys, when present in the
+// context, to the attributes of every span started by the provider.
+func WithBaggageAttributes(keys ...string) ProviderOption {
+	return func(p *TracerProvider) {
+		p.baggageKeys = append(p.baggageKeys, keys...)
+	}
+}
+
+// WithExporters registers exporters on the provider.
+func WithExporters(exporters ...Exporter) ProviderOption {
+	return func(p *TracerProvider) {
+		for _, e := range exporters {
+			p.RegisterExporter(e)
+		}
+	}
+}
+
+func NewTracerProvider(opts ...ProviderOption) *TracerProvider {
+	p := &TracerProvider{
+		resource: DetectResource(),
+		limits:   DefaultSpanLimits(),
+	}
+	p.exporters.Store(make(exportersMap))
+	p.sampler.Store(AlwaysSample())
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+var defaultProvider atomic.Value
+
+func init() {
+	defaultProvider.Store(NewTracerProvider())
+}
+
+// DefaultTracerProvider returns the provider used by StartSpan,
+// RegisterExporter and SetDefaultSampler.
+func DefaultTracerProvider() *TracerProvider {
+	return defaultProvider.Load().(*TracerProvider)
+}
+
+// SetDefaultTracerProvider replaces the provider used by StartSpan,
+// RegisterExporter and SetDefaultSampler.
+func SetDefaultTracerProvider(p *TracerProvider) {
+	if p == nil {
+		return
+	}
+	defaultProvider.Store(p)
+}
+
+// Tracer returns a tracer of the provider. The name identifies the
+// instrumented library.
+func (p *TracerProvider) Tracer(name string) *Tracer {
+	return &Tracer{
+		provider: p,
+		name:     name,
+	}
+}
+
+// RegisterExporter adds an exporter that receives the ended spans of the
+// provider.
+func (p *TracerProvider) RegisterExporter(e Exporter) {
+	p.exporterMu.Lock()
+	new := make(exportersMap)
+	for k, v := range p.loadExporters() {
+		new[k] = v
+	}
+	new[e] = struct{}{}
+	p.exporters.Store(new)
+	p.exporterMu.Unlock()
+}
+
+// UnregisterExporter removes an exporter added with RegisterExporter.
+func (p *TracerProvider) UnregisterExporter(e Exporter) {
+	p.exporterMu.Lock()
+	new := make(exportersMap)
+	for k, v := range p.loadExporters() {
+		new[k] = v
+	}
+	delete(new, e)
+	p.exporters.Store(new)
+	p.exporterMu.Unlock()
+}
+
+// SetSampler sets the sampler consulted for root spans and spans with a remote
+// parent.
+func (p *TracerProvider) SetSampler(s Sampler) {
+	if s == nil {
+		s = AlwaysSample()
+	}
+	p.sampler.Store(s)
+}
+
+// ForceFlush flushes every exporter that buffers spans, such as a
+// BatchProcessor.
+func (p *TracerProvider) ForceFlush(ctx context.Context) error {
+	var firstErr error
+	for e := range p.loadExporters() {
+		if f, ok := e.(interface {
+			ForceFlush(ctx context.Context) error
+		}); ok {
+			if err := f.ForceFlush(ctx); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// Shutdown stops the provider: spans started afterwards are not recorded, and
+// every exporter is unregistered after being shut down if it supports it. It
+// is meant to be registered as a clean-up action of the shutdown handler.
+func (p *TracerProvider) Shutdown(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&p.isShutdown, 0, 1) {
+		return nil
+	}
+	p.exporterMu.Lock()
+	exp := p.loadExporters()
+	p.exporters.Store(make(exportersMap))
+	p.exporterMu.Unlock()
+
+	var firstErr error
+	for e := range exp {
+		if s, ok := e.(interface {
+			Shutdown(ctx context.Context) error
+		}); ok {
+			if err := s.Shutdown(ctx); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+func (p *TracerProvider) loadExporters() exportersMap {
+	exp, _ := p.exporters.Load().(exportersMap)
+	return exp
+}
+
+func (p *TracerProvider) loadSampler() Sampler {
+	return p.sampler.Load().(Sampler)
+}
+
+func (p *TracerProvider) idGenerator() id.IDGenerator {
+	if p.idGen != nil {
+		return p.idGen
+	}
+	return id.TraceGen
+}
+
+// SpanStartObserver is implemented by exporters that are notified when a
+// recording span starts, for instance to count the spans in flight.
+type SpanStartObserver interface {
+	SpanStarted(name string)
+}
+
+func (p *TracerProvider) spanStarted(name string) {
+	for e := range p.loadExporters() {
+		if o, ok := e.(SpanStartObserver); ok {
+			o.SpanStarted(name)
+		}
+	}
+}
+
+func (p *TracerProvider) export(sd *SpanData) {
+	for e := range p.loadExporters() {
+		e.ExportSpan(sd)
+	}
+}
+
+// Tracer starts spans that belong to a TracerProvider.
+type Tracer struct {
+	provider *TracerProvider
+	name     string
+}
+
+// Start starts a span, which is a child of the span found in ctx if any.
+func (t *Tracer) Start(ctx context.Context, name string, opts ...StartOption) (context.Context, trace.ISpan) {
+	var parent spancontext.SpanContext
+	if p, ok := spancontext.FromContext(ctx).(*Span); ok && p != nil {
+		p.addChild()
+		parent = p.spanContext
+	}
+	return t.start(ctx, name, parent != spancontext.SpanContext{}, parent, false, opts)
+}
+
+// StartWithRemoteParent starts a child span of the given parent, which
+// usually comes from a Propagator extracting an incoming request.
+//
+// If the incoming context contains a parent, it is ignored.
+func (t *Tracer) StartWithRemoteParent(ctx context.Context, name string, parent spancontext.SpanContext, opts ...StartOption) (context.Context, trace.ISpan) {
+	return t.start(ctx, name, parent.IsValid(), parent, true, opts)
+}
+
+func (t *Tracer) start(ctx context.Context, name string, hasParent bool, parent spancontext.SpanContext, remoteParent bool, opts []StartOption) (context.Context, trace.ISpan) {
+	var o StartOptions
+	for _, op := range opts {
+		op(&o)
+	}
+	span := startSpanInternal(t, name, hasParent, parent, remoteParent, o)
+	if span.IsRecordingEvents() {
+		t.provider.spanStarted(name)
+	}
+	for _, k := range t.provider.baggageKeys {
+		if v := baggage.Value(ctx, k); v != "" {
+			span.SetAttribute(k, v)
+		}
+	}
+	ctx, end := startExecutionTracerTask(ctx, name)
+	span.executionTracerTaskEnd = end
+	if o.ProfilerLabels {
+		span.SetAttributes(o.ProfilerAttributes...)
+		ctx, span.profilerLabelsEnd = startProfilerLabels(ctx, name, o.ProfilerAttributes)
+	}
+	return spancontext.NewContext(ctx, span), span
+}
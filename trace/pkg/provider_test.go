@@ -0,0 +1,107 @@
+package tracepkg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/thnthien/great-deku/trace/pkg/baggage"
+	"github.com/thnthien/great-deku/trace/pkg/id"
+)
+
+type fixedIDGenerator struct{}
+
+func (fixedIDGenerator) NewTraceID() string { return "0102030405060708090a0b0c0d0e0f10" }
+func (fixedIDGenerator) NewSpanID() string  { return "0102030405060708" }
+
+func TestTracerProvider(t *testing.T) {
+	exp := &collectExporter{}
+	res := &Resource{Attributes: map[string]interface{}{"service.name": "orders"}}
+	p := NewTracerProvider(
+		WithExporters(exp),
+		WithIDGenerator(fixedIDGenerator{}),
+		WithResource(res),
+		WithProviderSampler(AlwaysSample()),
+	)
+	global := newCollectExporter(t)
+
+	_, span := p.Tracer("orders").Start(context.Background(), "create")
+	span.End()
+
+	sd := exp.byName("create")
+	if sd == nil {
+		t.Fatal("span not exported by its provider")
+	}
+	if sd.TraceID != "0102030405060708090a0b0c0d0e0f10" || sd.Resource != res {
+		t.Fatalf("provider configuration not applied: %+v", sd)
+	}
+	if global.byName("create") != nil {
+		t.Fatal("span leaked to the default provider")
+	}
+
+	p.UnregisterExporter(exp)
+	_, span = p.Tracer("orders").Start(context.Background(), "after unregister")
+	span.End()
+	if exp.byName("after unregister") != nil {
+		t.Fatal("span exported after unregister")
+	}
+}
+
+func TestTracerProvider_Shutdown(t *testing.T) {
+	rec := &batchRecorder{}
+	bp := NewBatchProcessor(rec, WithBatchTimeout(time.Hour))
+	p := NewTracerProvider(WithExporters(bp))
+
+	_, span := p.Tracer("").Start(context.Background(), "s")
+	span.End()
+	if err := p.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, n := rec.count(); n != 1 {
+		t.Fatalf("flushed %d spans, want 1", n)
+	}
+
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := bp.ForceFlush(context.Background()); err != ErrProcessorShutdown {
+		t.Fatalf("exporter not shut down: %v", err)
+	}
+	_, span = p.Tracer("").Start(context.Background(), "late")
+	if span.IsRecordingEvents() {
+		t.Fatal("span recorded after shutdown")
+	}
+}
+
+func TestStartSpan_DefaultProvider(t *testing.T) {
+	exp := newCollectExporter(t)
+	_, span := StartSpan(context.Background(), "default")
+	span.End()
+	if exp.byName("default") == nil {
+		t.Fatal("StartSpan does not use the default provider")
+	}
+	if id.TraceGen == nil {
+		t.Fatal("default id generator unset")
+	}
+}
+
+func TestTracerProvider_BaggageAttributes(t *testing.T) {
+	exp := &collectExporter{}
+	p := NewTracerProvider(WithExporters(exp), WithBaggageAttributes("tenant", "missing"))
+
+	ctx := baggage.WithValue(context.Background(), "tenant", "acme")
+	ctx = baggage.WithValue(ctx, "user", "42")
+	_, span := p.Tracer("").Start(ctx, "op")
+	span.End()
+
+	sd := exp.byName("op")
+	if sd.Attributes["tenant"] != "acme" {
+		t.Fatalf("tenant = %v", sd.Attributes["tenant"])
+	}
+	if _, ok := sd.Attributes["user"]; ok {
+		t.Fatal("unselected baggage key copied")
+	}
+	if _, ok := sd.Attributes["missing"]; ok {
+		t.Fatal("absent baggage key copied")
+	}
+}
@@ -0,0 +1,97 @@
+package tracepkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/thnthien/great-deku/trace"
+)
+
+// PanicError is the error recorded on a span for a recovered panic. The
+// exception event of the span holds its stack.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// MarshalJSON writes the error message only, since the stack is already held
+// by the exception event and the panic value may not be encodable.
+func (e *PanicError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.Error())
+}
+
+// Unwrap returns the panic value when it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+type recoverOptions struct {
+	repanic bool
+}
+
+// RecoverOption configures RecoverAndEnd and WithSpan.
+type RecoverOption func(o *recoverOptions)
+
+// WithRepanic makes RecoverAndEnd and WithSpan panic again with the recovered
+// value once the span is ended.
+func WithRepanic() RecoverOption {
+	return func(o *recoverOptions) {
+		o.repanic = true
+	}
+}
+
+// RecoverAndEnd ends span, recording the panic being recovered, if any, as a
+// PanicError. It must be deferred directly:
+//
+//	ctx, span := tracepkg.StartSpan(ctx, "work")
+//	defer tracepkg.RecoverAndEnd(span)
+//
+// Unless WithRepanic is given, the panic stops there and the function
+// deferring RecoverAndEnd returns normally.
+func RecoverAndEnd(span trace.ISpan, opts ...RecoverOption) {
+	if p := recover(); p != nil {
+		endWithPanic(span, p, opts)
+		return
+	}
+	span.End()
+}
+
+// WithSpan runs f in a new span, which is a child of the span found in ctx if
+// any. The span is ended when f returns or panics. The error returned by f is
+// recorded on the span and returned. A panic is recovered and recorded, then
+// returned as a PanicError unless WithRepanic is given.
+func WithSpan(ctx context.Context, name string, f func(ctx context.Context) error, opts ...RecoverOption) (err error) {
+	ctx, span := StartSpan(ctx, name)
+	defer func() {
+		if p := recover(); p != nil {
+			err = endWithPanic(span, p, opts)
+			return
+		}
+		if err != nil {
+			span.SetError(err)
+		}
+		span.End()
+	}()
+	return f(ctx)
+}
+
+func endWithPanic(span trace.ISpan, p interface{}, opts []RecoverOption) error {
+	var o recoverOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	err := &PanicError{Value: p, Stack: debug.Stack()}
+	span.SetError(err)
+	span.End()
+	if o.repanic {
+		panic(p)
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+package tracepkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRecoverAndEnd(t *testing.T) {
+	exp := &collectExporter{}
+	tr := NewTracerProvider(WithExporters(exp)).Tracer("")
+
+	func() {
+		_, span := tr.Start(context.Background(), "recovered")
+		defer RecoverAndEnd(span)
+		panic("boom")
+	}()
+	sd := exp.byName("recovered")
+	if sd == nil || !sd.IsError() {
+		t.Fatalf("panicking span not ended as an error: %+v", sd)
+	}
+	e := sd.Events[len(sd.Events)-1]
+	if e.Attributes["exception.message"] != "panic: boom" || e.Attributes["exception.type"] != "string" {
+		t.Fatalf("event = %+v", e)
+	}
+	if st, _ := e.Attributes["exception.stacktrace"].(string); !strings.Contains(st, "TestRecoverAndEnd") {
+		t.Fatalf("stack = %q", st)
+	}
+
+	func() {
+		defer func() {
+			if p := recover(); p != "again" {
+				t.Fatalf("recovered %v, want the re-panicked value", p)
+			}
+		}()
+		_, span := tr.Start(context.Background(), "repanic")
+		defer RecoverAndEnd(span, WithRepanic())
+		panic("again")
+	}()
+	func() {
+		_, span := tr.Start(context.Background(), "func value")
+		defer RecoverAndEnd(span)
+		panic(func() {})
+	}()
+	b, err := json.Marshal(exp.byName("func value"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"error":"panic: 0x`) || strings.Contains(string(b), `"Stack"`) {
+		t.Fatalf("json = %s", b)
+	}
+
+	if exp.byName("repanic") == nil {
+		t.Fatal("span not ended before re-panicking")
+	}
+
+	func() {
+		_, span := tr.Start(context.Background(), "ok")
+		defer RecoverAndEnd(span)
+	}()
+	if sd := exp.byName("ok"); sd == nil || sd.IsError() {
+		t.Fatalf("span = %+v", sd)
+	}
+}
+
+func TestWithSpan(t *testing.T) {
+	exp := newCollectExporter(t)
+	errBoom := errors.New("boom")
+
+	err := WithSpan(context.Background(), "failing", func(ctx context.Context) error {
+		if _, ok := SpanContextFromContext(ctx); !ok {
+			t.Error("span not in the context of f")
+		}
+		return errBoom
+	})
+	if err != errBoom {
+		t.Fatalf("err = %v", err)
+	}
+	if sd := exp.byName("failing"); sd == nil || sd.Error != errBoom {
+		t.Fatalf("span = %+v", sd)
+	}
+
+	err = WithSpan(context.Background(), "panicking", func(ctx context.Context) error {
+		panic(errBoom)
+	})
+	var pe *PanicError
+	if !errors.As(err, &pe) || !errors.Is(err, errBoom) || len(pe.Stack) == 0 {
+		t.Fatalf("err = %v", err)
+	}
+	if sd := exp.byName("panicking"); sd == nil || !sd.IsError() {
+		t.Fatalf("span = %+v", sd)
+	}
+}
@@ -0,0 +1,104 @@
+package tracepkg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/thnthien/great-deku/trace"
+)
+
+// Resource attribute keys, following the OpenTelemetry semantic conventions.
+const (
+	ServiceNameKey           = "service.name"
+	ServiceVersionKey        = "service.version"
+	DeploymentEnvironmentKey = "deployment.environment"
+	HostNameKey              = "host.name"
+	ProcessPIDKey            = "process.pid"
+)
+
+// Resource describes the entity producing spans, such as a service instance.
+type Resource struct {
+	Attributes map[string]interface{}
+}
+
+// NewResource returns a resource with the given attributes only.
+func NewResource(attrs ...trace.Attribute) *Resource {
+	r := &Resource{Attributes: make(map[string]interface{}, len(attrs))}
+	for _, a := range attrs {
+		r.Attributes[a.Key] = a.Value
+	}
+	return r
+}
+
+// DetectResource returns a resource describing the current process, with the
+// given attributes overriding the detected ones.
+//
+// The service name is read from SERVICE_NAME or OTEL_SERVICE_NAME and defaults
+// to the executable name. The version is read from SERVICE_VERSION and the
+// environment from ENVIRONMENT or ENV. Extra attributes can be set with
+// OTEL_RESOURCE_ATTRIBUTES as comma separated key=value pairs.
+func DetectResource(overrides ...trace.Attribute) *Resource {
+	r := NewResource()
+	for _, kv := range strings.Split(os.Getenv("OTEL_RESOURCE_ATTRIBUTES"), ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if ok && strings.TrimSpace(k) != "" {
+			r.Attributes[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+
+	if name := firstEnv("SERVICE_NAME", "OTEL_SERVICE_NAME"); name != "" {
+		r.Attributes[ServiceNameKey] = name
+	} else if _, ok := r.Attributes[ServiceNameKey]; !ok {
+		if exe, err := os.Executable(); err == nil {
+			r.Attributes[ServiceNameKey] = filepath.Base(exe)
+		}
+	}
+	if v := os.Getenv("SERVICE_VERSION"); v != "" {
+		r.Attributes[ServiceVersionKey] = v
+	}
+	if env := firstEnv("ENVIRONMENT", "ENV"); env != "" {
+		r.Attributes[DeploymentEnvironmentKey] = env
+	}
+	if host, err := os.Hostname(); err == nil {
+		r.Attributes[HostNameKey] = host
+	}
+	r.Attributes[ProcessPIDKey] = int64(os.Getpid())
+
+	return r.Merge(NewResource(overrides...))
+}
+
+// Merge returns a new resource with the attributes of both resources, the
+// attributes of other taking precedence.
+func (r *Resource) Merge(other *Resource) *Resource {
+	out := NewResource()
+	if r != nil {
+		for k, v := range r.Attributes {
+			out.Attributes[k] = v
+		}
+	}
+	if other != nil {
+		for k, v := range other.Attributes {
+			out.Attributes[k] = v
+		}
+	}
+	return out
+}
+
+// ServiceName returns the service.name attribute.
+func (r *Resource) ServiceName() string {
+	if r == nil {
+		return ""
+	}
+	name, _ := r.Attributes[ServiceNameKey].(string)
+	return name
+}
+
+func firstEnv(keys ...string) string {
+	for _, k := range keys {
+		if v := os.Getenv(k); v != "" {
+			return v
+		}
+	}
+	return ""
+}
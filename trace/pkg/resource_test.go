@@ -0,0 +1,57 @@
+package tracepkg
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/thnthien/great-deku/trace"
+)
+
+func TestDetectResource(t *testing.T) {
+	t.Setenv("SERVICE_NAME", "orders")
+	t.Setenv("SERVICE_VERSION", "1.2.3")
+	t.Setenv("ENV", "staging")
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "team=payments, region = eu")
+
+	r := DetectResource(trace.Attribute{Key: ServiceVersionKey, Value: "1.2.4"})
+	want := map[string]interface{}{
+		ServiceNameKey:           "orders",
+		ServiceVersionKey:        "1.2.4",
+		DeploymentEnvironmentKey: "staging",
+		ProcessPIDKey:            int64(os.Getpid()),
+		"team":                   "payments",
+		"region":                 "eu",
+	}
+	for k, v := range want {
+		if r.Attributes[k] != v {
+			t.Errorf("%s = %v, want %v", k, r.Attributes[k], v)
+		}
+	}
+	if host, _ := os.Hostname(); r.Attributes[HostNameKey] != host {
+		t.Errorf("host.name = %v", r.Attributes[HostNameKey])
+	}
+}
+
+func TestResource_Exported(t *testing.T) {
+	exp := &collectExporter{}
+	p := NewTracerProvider(WithExporters(exp), WithResource(NewResource(trace.Attribute{Key: ServiceNameKey, Value: "billing"})))
+	_, span := p.Tracer("").Start(context.Background(), "charge")
+	span.End()
+
+	sd := exp.byName("charge")
+	if sd.Resource.ServiceName() != "billing" {
+		t.Fatalf("resource = %+v", sd.Resource)
+	}
+	req := otlpRequest([]*SpanData{sd, sd})
+	if len(req.ResourceSpans) != 1 || len(req.ResourceSpans[0].ScopeSpans[0].Spans) != 2 {
+		t.Fatalf("spans not grouped by resource: %+v", req)
+	}
+	if kv := req.ResourceSpans[0].Resource.Attributes; len(kv) != 1 || *kv[0].Value.StringValue != "billing" {
+		t.Fatalf("otlp resource = %+v", kv)
+	}
+	z := (&ZipkinExporter{}).zipkinSpanFromData(sd)
+	if z.LocalEndpoint == nil || z.LocalEndpoint.ServiceName != "billing" {
+		t.Fatalf("zipkin local endpoint = %+v", z.LocalEndpoint)
+	}
+}
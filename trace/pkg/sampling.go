@@ -0,0 +1,136 @@
+package tracepkg
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/thnthien/great-deku/trace/pkg/id"
+	spancontext "github.com/thnthien/great-deku/trace/pkg/span-context"
+)
+
+// Sampler decides whether a trace should be sampled and exported.
+type Sampler func(SamplingParameters) SamplingDecision
+
+// SamplingParameters contains the values passed to a sampler.
+type SamplingParameters struct {
+	ParentContext   spancontext.SpanContext
+	TraceID         id.TraceID
+	SpanID          id.SpanID
+	Name            string
+	HasRemoteParent bool
+}
+
+// SamplingDecision is the value returned by a sampler.
+type SamplingDecision struct {
+	Sample bool
+}
+
+// AlwaysSample returns a Sampler that samples every trace.
+func AlwaysSample() Sampler {
+	return func(p SamplingParameters) SamplingDecision {
+		return SamplingDecision{Sample: true}
+	}
+}
+
+// NeverSample returns a Sampler that samples no traces.
+func NeverSample() Sampler {
+	return func(p SamplingParameters) SamplingDecision {
+		return SamplingDecision{Sample: false}
+	}
+}
+
+// ProbabilitySampler returns a Sampler that samples a given fraction of
+// traces. The decision is derived from the low 8 bytes of the trace ID, which
+// are random even for 64-bit IDs padded to 128 bits, so every service using
+// the same fraction makes the same decision for a trace.
+//
+// It also samples spans whose parents are sampled.
+func ProbabilitySampler(fraction float64) Sampler {
+	if !(fraction >= 0) {
+		fraction = 0
+	} else if fraction >= 1 {
+		return AlwaysSample()
+	}
+
+	traceIDUpperBound := uint64(fraction * (1 << 63))
+	return func(p SamplingParameters) SamplingDecision {
+		if p.ParentContext.IsSampled() {
+			return SamplingDecision{Sample: true}
+		}
+		x, err := strconv.ParseUint(lastN(string(p.TraceID), 16), 16, 64)
+		if err != nil {
+			return SamplingDecision{Sample: false}
+		}
+		return SamplingDecision{Sample: x>>1 < traceIDUpperBound}
+	}
+}
+
+// ParentBased returns a Sampler that follows the sampled flag of the parent
+// span, local or remote, and uses root for spans without a parent.
+func ParentBased(root Sampler) Sampler {
+	return func(p SamplingParameters) SamplingDecision {
+		if p.ParentContext.IsValid() {
+			return SamplingDecision{Sample: p.ParentContext.IsSampled()}
+		}
+		return root(p)
+	}
+}
+
+// RateLimitingSampler returns a Sampler that samples at most perSecond traces
+// per second. Spans of an already sampled trace are always sampled, so traces
+// are kept whole.
+func RateLimitingSampler(perSecond float64) Sampler {
+	rl := &rateLimiter{
+		rate:    perSecond,
+		balance: perSecond,
+		max:     perSecond,
+		last:    time.Now(),
+	}
+	if rl.max < 1 {
+		rl.max = 1
+	}
+	return func(p SamplingParameters) SamplingDecision {
+		if p.ParentContext.IsSampled() {
+			return SamplingDecision{Sample: true}
+		}
+		return SamplingDecision{Sample: rl.allow()}
+	}
+}
+
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	balance float64
+	max     float64
+	last    time.Time
+}
+
+func (r *rateLimiter) allow() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	r.balance += now.Sub(r.last).Seconds() * r.rate
+	if r.balance > r.max {
+		r.balance = r.max
+	}
+	r.last = now
+	if r.balance < 1 {
+		return false
+	}
+	r.balance--
+	return true
+}
+
+// SetDefaultSampler sets the sampler of the default TracerProvider, used when
+// a span is started without WithSampler. The default is AlwaysSample.
+func SetDefaultSampler(s Sampler) {
+	DefaultTracerProvider().SetSampler(s)
+}
+
+func lastN(s string, n int) string {
+	if len(s) < n {
+		return s
+	}
+	return s[len(s)-n:]
+}
@@ -0,0 +1,114 @@
+package tracepkg
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	spancontext "github.com/thnthien/great-deku/trace/pkg/span-context"
+)
+
+func TestStartSpan_Sampling(t *testing.T) {
+	ctx, root := StartSpan(context.Background(), "root", WithSampler(NeverSample()))
+	if root.IsRecordingEvents() || root.GetSpanData() != nil {
+		t.Fatal("unsampled span records events")
+	}
+	if root.GetTraceID() == "" || root.GetSpanID() == "" {
+		t.Fatal("unsampled span lost its span context")
+	}
+	root.SetAttribute("k", "v")
+	root.SetError(nil)
+	root.SetWarnDuration(0)
+
+	_, child := StartSpan(ctx, "child")
+	if child.IsRecordingEvents() {
+		t.Fatal("child of unsampled local parent is sampled")
+	}
+	if child.GetTraceID() != root.GetTraceID() {
+		t.Fatal("trace id not propagated to unsampled child")
+	}
+	child.End()
+	root.End()
+}
+
+func TestParentBased(t *testing.T) {
+	s := ParentBased(NeverSample())
+	sampled := spancontext.SpanContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7"}
+	sampled.TraceOptions.SetIsSampled(true)
+	notSampled := sampled
+	notSampled.TraceOptions.SetIsSampled(false)
+
+	if !s(SamplingParameters{ParentContext: sampled}).Sample {
+		t.Fatal("sampled parent not followed")
+	}
+	if s(SamplingParameters{ParentContext: notSampled}).Sample {
+		t.Fatal("unsampled parent not followed")
+	}
+	if s(SamplingParameters{}).Sample {
+		t.Fatal("root sampler not used")
+	}
+
+	_, span := StartSpanWithRemoteParent(context.Background(), "server", notSampled, WithSampler(ParentBased(AlwaysSample())))
+	if span.IsRecordingEvents() {
+		t.Fatal("remote unsampled parent not followed")
+	}
+}
+
+func TestProbabilitySampler(t *testing.T) {
+	if ProbabilitySampler(0)(SamplingParameters{TraceID: "ffffffffffffffffffffffffffffffff"}).Sample {
+		t.Fatal("fraction 0 sampled a trace")
+	}
+	if !ProbabilitySampler(1)(SamplingParameters{TraceID: "ffffffffffffffffffffffffffffffff"}).Sample {
+		t.Fatal("fraction 1 dropped a trace")
+	}
+
+	s := ProbabilitySampler(0.5)
+	if !s(SamplingParameters{TraceID: "ffffffffffffffff0000000000000001"}).Sample {
+		t.Fatal("low trace id not sampled")
+	}
+	if s(SamplingParameters{TraceID: "0000000000000000ffffffffffffffff"}).Sample {
+		t.Fatal("high trace id sampled")
+	}
+
+	n := 0
+	for i := 0; i < 10000; i++ {
+		_, span := StartSpan(context.Background(), "p", WithSampler(ProbabilitySampler(0.25)))
+		if span.IsRecordingEvents() {
+			n++
+		}
+	}
+	if n < 2000 || n > 3000 {
+		t.Fatalf("sampled %d of 10000 at 0.25", n)
+	}
+}
+
+func TestProbabilitySampler_PaddedB3TraceID(t *testing.T) {
+	s := ProbabilitySampler(1e-4)
+	n := 0
+	for i := 0; i < 1000; i++ {
+		sc, ok := newB3SpanContext(fmt.Sprintf("%016x", rand.Uint64()|1), "0102030405060708", "", "")
+		if !ok || len(sc.TraceID) != 32 {
+			t.Fatalf("b3 trace id = %q", sc.TraceID)
+		}
+		if s(SamplingParameters{TraceID: sc.TraceID}).Sample {
+			n++
+		}
+	}
+	if n > 5 {
+		t.Fatalf("sampled %d of 1000 padded 64-bit trace ids at 1e-4", n)
+	}
+}
+
+func TestRateLimitingSampler(t *testing.T) {
+	s := RateLimitingSampler(5)
+	n := 0
+	for i := 0; i < 100; i++ {
+		if s(SamplingParameters{}).Sample {
+			n++
+		}
+	}
+	if n < 5 || n > 6 {
+		t.Fatalf("sampled %d spans, want about 5", n)
+	}
+}
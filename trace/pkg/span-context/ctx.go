@@ -9,9 +9,40 @@ import (
 
 type contextKey struct{}
 
+// TraceOptions contains options associated with a trace span.
+type TraceOptions uint32
+
+// IsSampled returns true if the span will be exported.
+func (o TraceOptions) IsSampled() bool {
+	return o&1 == 1
+}
+
+// SetIsSampled sets the sampled bit of the trace options.
+func (o *TraceOptions) SetIsSampled(b bool) {
+	if b {
+		*o |= 1
+	} else {
+		*o &^= 1
+	}
+}
+
 type SpanContext struct {
-	TraceID id.TraceID
-	SpanID  id.SpanID
+	TraceID      id.TraceID
+	SpanID       id.SpanID
+	TraceOptions TraceOptions `json:"-"`
+	// Tracestate holds the vendor specific trace state, as carried by the W3C
+	// tracestate header.
+	Tracestate string `json:",omitempty"`
+}
+
+// IsSampled returns true if the span will be exported.
+func (sc SpanContext) IsSampled() bool {
+	return sc.TraceOptions.IsSampled()
+}
+
+// IsValid returns true if both trace and span IDs are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != "" && sc.SpanID != ""
 }
 
 // FromContext returns the Span stored in a context, or nil if there isn't one.
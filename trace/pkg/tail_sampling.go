@@ -0,0 +1,299 @@
+package tracepkg
+
+import (
+	"container/list"
+	"context"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/thnthien/great-deku/trace/pkg/id"
+)
+
+type tailConfig struct {
+	decisionWait     time.Duration
+	maxTraces        int
+	maxSpansPerTrace int
+	fraction         float64
+}
+
+// TailSamplingOption configures a TailSamplingProcessor.
+type TailSamplingOption func(c *tailConfig)
+
+// WithDecisionWait sets how long the spans of a trace are buffered when its
+// local root does not end. The default is 30s.
+func WithDecisionWait(d time.Duration) TailSamplingOption {
+	return func(c *tailConfig) {
+		c.decisionWait = d
+	}
+}
+
+// WithMaxTraces sets the number of traces buffered at once. When it is
+// reached, the oldest trace is decided early. The default is 10000.
+func WithMaxTraces(n int) TailSamplingOption {
+	return func(c *tailConfig) {
+		c.maxTraces = n
+	}
+}
+
+// WithMaxSpansPerTrace sets the number of spans buffered for a trace. Further
+// spans of the trace are dropped, except its local root which is always kept.
+// The default is 1000.
+func WithMaxSpansPerTrace(n int) TailSamplingOption {
+	return func(c *tailConfig) {
+		c.maxSpansPerTrace = n
+	}
+}
+
+// WithTailSampleFraction sets the fraction of the traces matching no other
+// rule that are exported anyway. The default is 0.
+func WithTailSampleFraction(f float64) TailSamplingOption {
+	return func(c *tailConfig) {
+		c.fraction = f
+	}
+}
+
+type tailTrace struct {
+	id       id.TraceID
+	spans    []*SpanData
+	deadline time.Time
+	elem     *list.Element
+	sample   bool
+}
+
+// TailSamplingProcessor is an Exporter that buffers the spans of every trace
+// until its local root ends, or the decision wait expires, and then hands the
+// whole trace to the next exporter only if:
+//   - any span of the trace is an error, or
+//   - the local root took longer than its WarnDuration, or
+//   - it is picked at random according to WithTailSampleFraction.
+//
+// Spans of a trace ending after its decision follow that decision.
+type TailSamplingProcessor struct {
+	next Exporter
+	cfg  tailConfig
+
+	mu      sync.Mutex
+	traces  map[id.TraceID]*tailTrace
+	pending *list.List // of *tailTrace, oldest first
+	decided map[id.TraceID]bool
+	order   []id.TraceID // ring of the keys of decided
+	nextKey int
+
+	stopOnce sync.Once
+	stopped  int32
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+	dropped  uint64
+}
+
+// NewTailSamplingProcessor creates a TailSamplingProcessor exporting to next
+// and starts its background goroutine.
+func NewTailSamplingProcessor(next Exporter, opts ...TailSamplingOption) *TailSamplingProcessor {
+	cfg := tailConfig{
+		decisionWait:     30 * time.Second,
+		maxTraces:        10000,
+		maxSpansPerTrace: 1000,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.decisionWait <= 0 {
+		cfg.decisionWait = 30 * time.Second
+	}
+	if cfg.maxTraces <= 0 {
+		cfg.maxTraces = 10000
+	}
+	if cfg.maxSpansPerTrace <= 0 {
+		cfg.maxSpansPerTrace = 1000
+	}
+
+	p := &TailSamplingProcessor{
+		next:    next,
+		cfg:     cfg,
+		traces:  make(map[id.TraceID]*tailTrace),
+		pending: list.New(),
+		decided: make(map[id.TraceID]bool),
+		stopCh:  make(chan struct{}),
+		doneCh:  make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// Start registers the processor as a global exporter.
+func (p *TailSamplingProcessor) Start() {
+	RegisterExporter(p)
+}
+
+// ExportSpan buffers sd until the trace is decided. When sd is the local root
+// of its trace, the trace is decided immediately. Spans are dropped once the
+// processor is shut down.
+func (p *TailSamplingProcessor) ExportSpan(sd *SpanData) {
+	if atomic.LoadInt32(&p.stopped) == 1 {
+		atomic.AddUint64(&p.dropped, 1)
+		return
+	}
+	p.mu.Lock()
+	if sample, ok := p.decided[sd.TraceID]; ok {
+		p.mu.Unlock()
+		if sample {
+			p.next.ExportSpan(sd)
+		} else {
+			atomic.AddUint64(&p.dropped, 1)
+		}
+		return
+	}
+
+	var done []*tailTrace
+	t, ok := p.traces[sd.TraceID]
+	if !ok {
+		if len(p.traces) >= p.cfg.maxTraces {
+			done = append(done, p.decideLocked(p.pending.Front().Value.(*tailTrace), nil))
+		}
+		t = &tailTrace{id: sd.TraceID, deadline: time.Now().Add(p.cfg.decisionWait)}
+		t.elem = p.pending.PushBack(t)
+		p.traces[sd.TraceID] = t
+	}
+	isRoot := sd.ParentSpanID == "" || sd.HasRemoteParent
+	if len(t.spans) < p.cfg.maxSpansPerTrace || isRoot {
+		t.spans = append(t.spans, sd)
+	} else {
+		atomic.AddUint64(&p.dropped, 1)
+	}
+
+	if isRoot {
+		done = append(done, p.decideLocked(t, sd))
+	}
+	p.mu.Unlock()
+	p.export(done)
+}
+
+// Dropped returns the number of spans dropped so far, either because their
+// trace was not sampled or because it had too many spans.
+func (p *TailSamplingProcessor) Dropped() uint64 {
+	return atomic.LoadUint64(&p.dropped)
+}
+
+// ForceFlush decides every buffered trace, then flushes the next exporter if
+// it supports it.
+func (p *TailSamplingProcessor) ForceFlush(ctx context.Context) error {
+	p.decideAll()
+	if f, ok := p.next.(interface {
+		ForceFlush(ctx context.Context) error
+	}); ok {
+		return f.ForceFlush(ctx)
+	}
+	return nil
+}
+
+// Shutdown decides every buffered trace, stops the background goroutine and
+// then shuts the next exporter down if it supports it, so that the decided
+// traces are exported. It is safe to call more than once.
+func (p *TailSamplingProcessor) Shutdown(ctx context.Context) error {
+	p.stopOnce.Do(func() {
+		atomic.StoreInt32(&p.stopped, 1)
+		close(p.stopCh)
+	})
+	select {
+	case <-p.doneCh:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if s, ok := p.next.(interface {
+		Shutdown(ctx context.Context) error
+	}); ok {
+		return s.Shutdown(ctx)
+	}
+	return nil
+}
+
+func (p *TailSamplingProcessor) run() {
+	defer close(p.doneCh)
+	interval := p.cfg.decisionWait / 4
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			p.export(p.expired(now))
+		case <-p.stopCh:
+			p.decideAll()
+			return
+		}
+	}
+}
+
+// expired decides the traces whose decision wait expired.
+func (p *TailSamplingProcessor) expired(now time.Time) []*tailTrace {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var out []*tailTrace
+	for e := p.pending.Front(); e != nil; e = p.pending.Front() {
+		t := e.Value.(*tailTrace)
+		if now.Before(t.deadline) {
+			break
+		}
+		out = append(out, p.decideLocked(t, nil))
+	}
+	return out
+}
+
+func (p *TailSamplingProcessor) decideAll() {
+	p.mu.Lock()
+	var all []*tailTrace
+	for e := p.pending.Front(); e != nil; e = p.pending.Front() {
+		all = append(all, p.decideLocked(e.Value.(*tailTrace), nil))
+	}
+	p.mu.Unlock()
+	p.export(all)
+}
+
+// decideLocked removes t from the buffer and remembers whether it is sampled
+// for the spans of the trace ending later. root is nil when the local root
+// has not ended.
+func (p *TailSamplingProcessor) decideLocked(t *tailTrace, root *SpanData) *tailTrace {
+	p.pending.Remove(t.elem)
+	delete(p.traces, t.id)
+	t.sample = p.sample(t.spans, root)
+
+	if len(p.order) < p.cfg.maxTraces {
+		p.order = append(p.order, t.id)
+	} else {
+		delete(p.decided, p.order[p.nextKey])
+		p.order[p.nextKey] = t.id
+		p.nextKey = (p.nextKey + 1) % p.cfg.maxTraces
+	}
+	p.decided[t.id] = t.sample
+	return t
+}
+
+// export hands the spans of the sampled traces to the next exporter.
+func (p *TailSamplingProcessor) export(traces []*tailTrace) {
+	for _, t := range traces {
+		if !t.sample {
+			atomic.AddUint64(&p.dropped, uint64(len(t.spans)))
+			continue
+		}
+		for _, sd := range t.spans {
+			p.next.ExportSpan(sd)
+		}
+	}
+}
+
+func (p *TailSamplingProcessor) sample(spans []*SpanData, root *SpanData) bool {
+	for _, sd := range spans {
+		if sd.IsError() {
+			return true
+		}
+	}
+	if root != nil && root.WarnDuration > 0 && root.DurationVal > root.WarnDuration {
+		return true
+	}
+	return p.cfg.fraction > 0 && rand.Float64() < p.cfg.fraction
+}
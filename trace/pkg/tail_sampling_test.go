@@ -0,0 +1,121 @@
+package tracepkg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTailSamplingProcessor(t *testing.T) {
+	exp := &collectExporter{}
+	tail := NewTailSamplingProcessor(exp)
+	defer tail.Shutdown(context.Background())
+	tr := NewTracerProvider(WithExporters(tail)).Tracer("")
+
+	// A failed child keeps the whole trace.
+	ctx, root := tr.Start(context.Background(), "failed root")
+	_, child := StartSpan(ctx, "failed child")
+	child.SetError(errors.New("boom"))
+	child.End()
+	if exp.byName("failed child") != nil {
+		t.Fatal("child exported before the root ended")
+	}
+	root.End()
+	if exp.byName("failed root") == nil || exp.byName("failed child") == nil {
+		t.Fatal("failed trace not exported")
+	}
+
+	// A slow root keeps the trace.
+	_, root = tr.Start(context.Background(), "slow root")
+	root.SetWarnDuration(time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	root.End()
+	if exp.byName("slow root") == nil {
+		t.Fatal("slow trace not exported")
+	}
+
+	// Anything else is dropped, including spans ending after the decision.
+	ctx, root = tr.Start(context.Background(), "ok root")
+	_, child = StartSpan(ctx, "late child")
+	root.End()
+	child.End()
+	if exp.byName("ok root") != nil || exp.byName("late child") != nil {
+		t.Fatal("uninteresting trace exported")
+	}
+	if n := tail.Dropped(); n != 2 {
+		t.Fatalf("dropped = %d, want 2", n)
+	}
+}
+
+func TestTailSamplingProcessor_Bounds(t *testing.T) {
+	exp := &collectExporter{}
+	tail := NewTailSamplingProcessor(exp,
+		WithDecisionWait(20*time.Millisecond),
+		WithMaxTraces(1),
+		WithMaxSpansPerTrace(1),
+		WithTailSampleFraction(1),
+	)
+	defer tail.Shutdown(context.Background())
+	tr := NewTracerProvider(WithExporters(tail)).Tracer("")
+
+	ctx, first := tr.Start(context.Background(), "first")
+	_, a := StartSpan(ctx, "a")
+	_, b := StartSpan(ctx, "b")
+	a.End()
+	b.End()
+	if n := tail.Dropped(); n != 1 {
+		t.Fatalf("dropped = %d, want the span over the per-trace limit", n)
+	}
+
+	// A second trace evicts the first one, which is decided early.
+	ctx2, second := tr.Start(context.Background(), "second")
+	_, c := StartSpan(ctx2, "c")
+	c.End()
+	if exp.byName("a") == nil {
+		t.Fatal("evicted trace not decided")
+	}
+
+	// The decision wait expires for the second trace.
+	deadline := time.Now().Add(time.Second)
+	for exp.byName("c") == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("trace not decided after the decision wait")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	first.End()
+	second.End()
+}
+
+func TestTailSamplingProcessor_RootOverLimit(t *testing.T) {
+	exp := &collectExporter{}
+	tail := NewTailSamplingProcessor(exp, WithMaxSpansPerTrace(1))
+	defer tail.Shutdown(context.Background())
+	tr := NewTracerProvider(WithExporters(tail)).Tracer("")
+
+	ctx, root := tr.Start(context.Background(), "root")
+	_, child := StartSpan(ctx, "child")
+	child.SetError(errors.New("boom"))
+	child.End()
+	root.End()
+	if exp.byName("root") == nil || exp.byName("child") == nil {
+		t.Fatal("root dropped by the per-trace limit")
+	}
+}
+
+func TestTailSamplingProcessor_ShutdownFlushesNext(t *testing.T) {
+	rec := &batchRecorder{}
+	tail := NewTailSamplingProcessor(NewBatchProcessor(rec, WithBatchTimeout(time.Hour)))
+	p := NewTracerProvider(WithExporters(tail))
+
+	_, span := p.Tracer("").Start(context.Background(), "failed")
+	span.SetError(errors.New("boom"))
+	span.End()
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, n := rec.count(); n != 1 {
+		t.Fatalf("exported %d spans at shutdown, want 1", n)
+	}
+}
@@ -2,6 +2,8 @@ package tracepkg
 
 import (
 	"context"
+	"fmt"
+	"runtime/pprof"
 	t "runtime/trace"
 	"sync"
 	"sync/atomic"
@@ -18,43 +20,149 @@ type Exporter interface {
 
 type exportersMap map[Exporter]struct{}
 
-var (
-	exporterMu sync.Mutex
-	exporters  atomic.Value
+// RegisterExporter adds an exporter to the default TracerProvider.
+func RegisterExporter(e Exporter) {
+	DefaultTracerProvider().RegisterExporter(e)
+}
+
+// UnregisterExporter removes an exporter from the default TracerProvider.
+func UnregisterExporter(e Exporter) {
+	DefaultTracerProvider().UnregisterExporter(e)
+}
+
+// SpanKind is the role of a span in a request, which lets backends pair client
+// and server spans.
+type SpanKind int
+
+const (
+	SpanKindUnspecified SpanKind = iota
+	SpanKindInternal
+	SpanKindServer
+	SpanKindClient
+	SpanKindProducer
+	SpanKindConsumer
 )
 
-func RegisterExporter(e Exporter) {
-	exporterMu.Lock()
-	new := make(exportersMap)
-	if old, ok := exporters.Load().(exportersMap); ok {
-		for k, v := range old {
-			new[k] = v
-		}
+func (k SpanKind) String() string {
+	switch k {
+	case SpanKindInternal:
+		return "INTERNAL"
+	case SpanKindServer:
+		return "SERVER"
+	case SpanKindClient:
+		return "CLIENT"
+	case SpanKindProducer:
+		return "PRODUCER"
+	case SpanKindConsumer:
+		return "CONSUMER"
 	}
-	new[e] = struct{}{}
-	exporters.Store(new)
-	exporterMu.Unlock()
+	return "UNSPECIFIED"
+}
+
+func (k SpanKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// Status is the result of the operation of a span.
+type Status struct {
+	Code        trace.StatusCode
+	Description string `json:",omitempty"`
 }
 
 // SpanData contains all the information collected by a Span.
 type SpanData struct {
 	Name string
 	spancontext.SpanContext
+	SpanKind     SpanKind
 	ParentSpanID id.SpanID
-	StartTime    time.Time
+	// HasRemoteParent is true when the parent span context was extracted
+	// from an incoming request rather than created in this process.
+	HasRemoteParent bool `json:",omitempty"`
+	StartTime       time.Time
 	// The wall clock time of EndTime will be adjusted to always be offset
 	// from StartTime by the duration of the span.
 	EndTime      time.Time
 	Duration     string
 	DurationVal  time.Duration `json:"-"`
 	WarnDuration time.Duration `json:"-"`
-	// The values of Attributes each have type string, bool, or int64.
+	// The values of Attributes each have type string, bool, int64, float64 or
+	// a slice of one of them.
 	Attributes map[string]interface{}
 
-	Error interface{} `json:"error,omitempty"`
+	Error  interface{} `json:"error,omitempty"`
+	Status Status
+	// Events holds the timestamped annotations added with AddEvent.
+	Events []Event `json:",omitempty"`
+	// Links holds spans of other traces this span relates to, such as the
+	// messages of a batch.
+	Links []Link `json:",omitempty"`
+	// Resource describes the service instance that produced the span.
+	Resource *Resource `json:",omitempty"`
 	// ChildSpanCount holds the number of child span created for this span.
 	ChildSpanCount int
-	MustLog        bool `json:"-"`
+	// The counts of attributes, events and links dropped by the SpanLimits.
+	DroppedAttributeCount int  `json:",omitempty"`
+	DroppedEventCount     int  `json:",omitempty"`
+	DroppedLinkCount      int  `json:",omitempty"`
+	MustLog               bool `json:"-"`
+}
+
+// Event is a timestamped annotation of a span.
+type Event struct {
+	Time                  time.Time
+	Name                  string
+	Attributes            map[string]interface{} `json:",omitempty"`
+	DroppedAttributeCount int                    `json:",omitempty"`
+}
+
+// Link points from a span to a span of another trace.
+type Link struct {
+	TraceID               id.TraceID
+	SpanID                id.SpanID
+	Attributes            map[string]interface{} `json:",omitempty"`
+	DroppedAttributeCount int                    `json:",omitempty"`
+
+	attrs []trace.Attribute // set by NewLink until the span starts
+}
+
+// NewLink returns a Link to sc, to be given to WithLinks. Its attributes are
+// bounded by the SpanLimits of the provider starting the span.
+func NewLink(sc spancontext.SpanContext, attrs ...trace.Attribute) Link {
+	return Link{
+		TraceID: sc.TraceID,
+		SpanID:  sc.SpanID,
+		attrs:   append([]trace.Attribute(nil), attrs...),
+	}
+}
+
+// IsError returns true if an error was set or the status is an error.
+func (s SpanData) IsError() bool {
+	return s.Error != nil || s.Status.Code == trace.StatusCodeError
+}
+
+// status returns the status of the span, falling back to Error when the
+// status was not set.
+func (s SpanData) status() Status {
+	if s.Status.Code == trace.StatusCodeUnset && s.Error != nil {
+		return Status{Code: trace.StatusCodeError, Description: fmt.Sprint(s.Error)}
+	}
+	return s.Status
+}
+
+// snapshot returns a copy of the span data that later mutations of the span
+// do not affect.
+func (s *SpanData) snapshot() *SpanData {
+	cp := *s
+	if s.Attributes != nil {
+		cp.Attributes = make(map[string]interface{}, len(s.Attributes))
+		for k, v := range s.Attributes {
+			cp.Attributes[k] = v
+		}
+	}
+	// events and links are never modified once added
+	cp.Events = append([]Event(nil), s.Events...)
+	cp.Links = append([]Link(nil), s.Links...)
+	return &cp
 }
 
 func (s SpanData) GetSpanID() string {
@@ -76,13 +184,25 @@ type Span struct {
 	spanContext spancontext.SpanContext
 
 	endOnce sync.Once
+	ended   bool // set when the span ends, after which mutations are ignored
+	limits  SpanLimits
 
 	executionTracerTaskEnd func() // ends the execution tracer span
+	profilerLabelsEnd      func() // restores the pprof labels of the goroutine
 	isExport               bool
+
+	// tracer is the tracer that started the span, it is nil for spans that
+	// only carry a remote span context.
+	tracer *Tracer
 }
 
 func (s *Span) NeedExport(b bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
 	s.isExport = b
+	s.mu.Unlock()
 }
 
 // IsRecordingEvents returns true if events are being recorded for this span.
@@ -95,115 +215,342 @@ func (s *Span) IsRecordingEvents() bool {
 	return s.data != nil
 }
 
-func (s *Span) addChild() {
+// mutate calls f with the lock held, unless the span is not recording events or
+// has already ended.
+func (s *Span) mutate(f func(sd *SpanData)) {
 	if !s.IsRecordingEvents() {
 		return
 	}
 	s.mu.Lock()
-	s.data.ChildSpanCount++
-	s.mu.Unlock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	f(s.data)
+}
+
+func (s *Span) addChild() {
+	s.mutate(func(sd *SpanData) {
+		sd.ChildSpanCount++
+	})
 }
 
+// SetAttribute sets an attribute of the span. Values are converted to the
+// types of the trace attribute constructors, and bounded by the SpanLimits.
 func (s *Span) SetAttribute(key string, val interface{}) {
-	if s.data.Attributes == nil {
-		s.data.Attributes = make(map[string]interface{})
+	s.mutate(func(sd *SpanData) {
+		s.setAttributeLocked(sd, key, val)
+	})
+}
+
+// SetAttributes sets attributes built with the typed constructors of package
+// trace.
+func (s *Span) SetAttributes(attrs ...trace.Attribute) {
+	s.mutate(func(sd *SpanData) {
+		for _, a := range attrs {
+			s.setAttributeLocked(sd, a.Key, a.Value)
+		}
+	})
+}
+
+func (s *Span) setAttributeLocked(sd *SpanData, key string, val interface{}) {
+	if sd.Attributes == nil {
+		sd.Attributes = make(map[string]interface{})
+	}
+	if !s.limits.setAttribute(sd.Attributes, key, val) {
+		sd.DroppedAttributeCount++
 	}
-	s.data.Attributes[key] = val
 }
 
+func (s *Span) addEventLocked(sd *SpanData, e Event) {
+	if !withinLimit(len(sd.Events), s.limits.EventCountLimit) {
+		sd.DroppedEventCount++
+		return
+	}
+	sd.Events = append(sd.Events, e)
+}
+
+// AddEvent records a timestamped event, such as a cache miss or a retry,
+// within the span.
+func (s *Span) AddEvent(name string, attrs ...trace.Attribute) {
+	if !s.IsRecordingEvents() {
+		return
+	}
+	e := Event{
+		Time: time.Now(),
+		Name: name,
+	}
+	e.Attributes, e.DroppedAttributeCount = s.limits.attributesMap(attrs)
+	s.mutate(func(sd *SpanData) {
+		s.addEventLocked(sd, e)
+	})
+}
+
+// EventCount returns the number of events added to the span, including those
+// dropped by the SpanLimits.
+func (s *Span) EventCount() int {
+	if !s.IsRecordingEvents() {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.data.Events) + s.data.DroppedEventCount
+}
+
+// AddLink links the span to the span identified by traceID and spanID,
+// usually the span of a message consumed from another trace.
+func (s *Span) AddLink(traceID, spanID string, attrs ...trace.Attribute) {
+	if !s.IsRecordingEvents() {
+		return
+	}
+	l := Link{
+		TraceID: id.TraceID(traceID),
+		SpanID:  id.SpanID(spanID),
+	}
+	l.Attributes, l.DroppedAttributeCount = s.limits.attributesMap(attrs)
+	s.mutate(func(sd *SpanData) {
+		if !withinLimit(len(sd.Links), s.limits.LinkCountLimit) {
+			sd.DroppedLinkCount++
+			return
+		}
+		sd.Links = append(sd.Links, l)
+	})
+}
+
+// SetError marks the span as failed: it sets an error status described by err
+// and records an exception event with the error type and message, and the
+// stack of a PanicError. SetError(nil) clears the error and the status set by
+// a previous SetError.
 func (s *Span) SetError(err error) {
-	s.isExport = true
-	s.data.Error = err
+	if !s.IsRecordingEvents() {
+		return
+	}
+	var e Event
+	if err != nil {
+		e = Event{
+			Time: time.Now(),
+			Name: "exception",
+		}
+		attrs := []trace.Attribute{
+			trace.String("exception.type", fmt.Sprintf("%T", err)),
+			trace.String("exception.message", err.Error()),
+		}
+		if pe, ok := err.(*PanicError); ok {
+			attrs[0] = trace.String("exception.type", fmt.Sprintf("%T", pe.Value))
+			attrs = append(attrs, trace.String("exception.stacktrace", string(pe.Stack)))
+		}
+		e.Attributes, e.DroppedAttributeCount = s.limits.attributesMap(attrs)
+	}
+	s.mutate(func(sd *SpanData) {
+		s.isExport = true
+		if err == nil {
+			// Clear the status set by a previous SetError.
+			if prev, ok := sd.Error.(error); ok && sd.Status.Code == trace.StatusCodeError && sd.Status.Description == prev.Error() {
+				sd.Status = Status{}
+			}
+			sd.Error = nil
+			return
+		}
+		sd.Error = err
+		sd.Status = Status{Code: trace.StatusCodeError, Description: err.Error()}
+		s.addEventLocked(sd, e)
+	})
+}
+
+// SetStatus sets the status of the span. The description is only kept for an
+// error status.
+func (s *Span) SetStatus(code trace.StatusCode, description string) {
+	if code != trace.StatusCodeError {
+		description = ""
+	}
+	s.mutate(func(sd *SpanData) {
+		sd.Status = Status{Code: code, Description: description}
+	})
 }
+
 func (s *Span) IsError() bool {
-	return s.data.Error != nil
+	if !s.IsRecordingEvents() {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.IsError()
 }
 
 func (s *Span) SetWarnDuration(d time.Duration) {
-	s.data.WarnDuration = d
+	s.mutate(func(sd *SpanData) {
+		sd.WarnDuration = d
+	})
 }
 
+// GetSpanData returns a snapshot of the data recorded so far, or nil for spans
+// that are not recording events.
 func (s *Span) GetSpanData() trace.ISpanData {
-	return s.data
+	if !s.IsRecordingEvents() {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.snapshot()
 }
 
 func (s *Span) GetTraceID() string {
-	return string(s.data.TraceID)
+	if s == nil {
+		return ""
+	}
+	return string(s.spanContext.TraceID)
 }
 func (s *Span) GetSpanID() string {
-	return string(s.data.SpanID)
+	if s == nil {
+		return ""
+	}
+	return string(s.spanContext.SpanID)
 }
 
-func StartSpan(ctx context.Context, name string) (context.Context, trace.ISpan) {
-	var parent spancontext.SpanContext
-	if p := spancontext.FromContext(ctx); p != nil {
-		p.(*Span).addChild()
-		parent = p.(*Span).spanContext
+// SpanContext returns the SpanContext of the span, which can be injected into
+// outgoing requests.
+func (s *Span) SpanContext() spancontext.SpanContext {
+	if s == nil {
+		return spancontext.SpanContext{}
 	}
-	span := startSpanInternal(name, parent != spancontext.SpanContext{}, parent)
-	ctx, end := startExecutionTracerTask(ctx, name)
-	span.executionTracerTaskEnd = end
-	return spancontext.NewContext(ctx, span), span
+	return s.spanContext
 }
 
-// End ends the span.
-func (s *Span) End() {
-	if s == nil {
-		return
+// SpanContextFromContext returns the SpanContext of the span stored in ctx.
+func SpanContextFromContext(ctx context.Context) (spancontext.SpanContext, bool) {
+	s, ok := spancontext.FromContext(ctx).(*Span)
+	if !ok || s == nil {
+		return spancontext.SpanContext{}, false
 	}
-	if s.executionTracerTaskEnd != nil {
-		s.executionTracerTaskEnd()
+	return s.spanContext, true
+}
+
+// StartOptions contains options concerning how a span is started.
+type StartOptions struct {
+	// Sampler to consult for this Span. If provided, it is always consulted.
+	//
+	// If not provided, then the behavior differs based on whether
+	// the parent of this Span is remote, local, or there is no parent.
+	// In the case of a remote parent or no parent, the
+	// default sampler (see SetDefaultSampler) will be consulted. Otherwise,
+	// when there is a non-remote parent, no new sampling decision will be made:
+	// we will preserve the sampling of the parent.
+	Sampler Sampler
+
+	// Links are added to the span when it starts.
+	Links []Link
+
+	// SpanKind represents the kind of a span. If none is set,
+	// SpanKindUnspecified is used.
+	SpanKind SpanKind
+
+	// ProfilerLabels makes the span set the runtime/pprof labels of the
+	// goroutine until it ends: "span" holds its name, and every attribute of
+	// ProfilerAttributes is a label too.
+	ProfilerLabels     bool
+	ProfilerAttributes []trace.Attribute
+}
+
+// WithSpanKind makes new spans to be created with the given kind.
+func WithSpanKind(kind SpanKind) StartOption {
+	return func(o *StartOptions) {
+		o.SpanKind = kind
 	}
-	if !s.IsRecordingEvents() {
-		return
+}
+
+// StartOption apply changes to StartOptions.
+type StartOption func(*StartOptions)
+
+// WithLinks links the new span to the given spans of other traces.
+func WithLinks(links ...Link) StartOption {
+	return func(o *StartOptions) {
+		o.Links = append(o.Links, links...)
 	}
-	s.endOnce.Do(func() {
-		sd := s.data
-		sd.EndTime = MonotonicEndTime(sd.StartTime)
-		sd.DurationVal = time.Since(sd.StartTime)
-		sd.Duration = sd.DurationVal.String()
+}
 
-		//if s.isExport {
-		exp, _ := exporters.Load().(exportersMap)
-		mustExport := len(exp) > 0
-		if mustExport {
-			for e := range exp {
-				e.ExportSpan(sd)
-			}
-		}
-		//}
-		//str, _ := json.Marshal(sd)
-		//fmt.Printf("==> end span => endOnce %s\n", str)
-	})
+// WithProfilerLabels makes new spans set the runtime/pprof labels of the
+// current goroutine to the span name and the given attributes, which are also
+// added to the span, so that CPU profiles can be broken down by span. The
+// previous labels are restored by End, which must then be called on the same
+// goroutine. Goroutines started meanwhile inherit the labels.
+func WithProfilerLabels(attrs ...trace.Attribute) StartOption {
+	return func(o *StartOptions) {
+		o.ProfilerLabels = true
+		o.ProfilerAttributes = append(o.ProfilerAttributes, attrs...)
+	}
 }
 
-func (s *Span) EndExport() {
-	if s == nil {
-		return
+// WithSampler makes new spans to be be created with a custom sampler.
+// Otherwise, the default sampler is used.
+func WithSampler(sampler Sampler) StartOption {
+	return func(o *StartOptions) {
+		o.Sampler = sampler
 	}
-	if s.executionTracerTaskEnd != nil {
-		s.executionTracerTaskEnd()
+}
+
+// StartSpan starts a span, which is a child of the span found in ctx if any.
+// The span belongs to the TracerProvider of its parent, or to the default
+// provider when it has no local parent.
+func StartSpan(ctx context.Context, name string, opts ...StartOption) (context.Context, trace.ISpan) {
+	tr := defaultTracer()
+	if p, ok := spancontext.FromContext(ctx).(*Span); ok && p != nil && p.tracer != nil {
+		tr = p.tracer
 	}
-	if !s.IsRecordingEvents() {
+	return tr.Start(ctx, name, opts...)
+}
+
+// StartSpanWithRemoteParent starts a new child span of the span from the given
+// parent, which usually comes from a Propagator extracting an incoming request.
+// The span belongs to the default TracerProvider.
+//
+// If the incoming context contains a parent, it is ignored.
+func StartSpanWithRemoteParent(ctx context.Context, name string, parent spancontext.SpanContext, opts ...StartOption) (context.Context, trace.ISpan) {
+	return defaultTracer().StartWithRemoteParent(ctx, name, parent, opts...)
+}
+
+func defaultTracer() *Tracer {
+	return DefaultTracerProvider().Tracer("")
+}
+
+// End ends the span and hands a snapshot of its data to the exporters of its
+// TracerProvider. Mutations after End are ignored.
+func (s *Span) End() {
+	s.end(false)
+}
+
+// EndExport ends the span like End, and makes the LogExporter log it at info
+// level at least.
+func (s *Span) EndExport() {
+	s.end(true)
+}
+
+func (s *Span) end(mustLog bool) {
+	if s == nil {
 		return
 	}
 	s.endOnce.Do(func() {
+		if s.executionTracerTaskEnd != nil {
+			s.executionTracerTaskEnd()
+		}
+		if s.profilerLabelsEnd != nil {
+			s.profilerLabelsEnd()
+		}
+		if !s.IsRecordingEvents() {
+			return
+		}
+		s.mu.Lock()
+		s.ended = true
 		sd := s.data
 		sd.EndTime = MonotonicEndTime(sd.StartTime)
 		sd.DurationVal = time.Since(sd.StartTime)
 		sd.Duration = sd.DurationVal.String()
-		sd.MustLog = true
+		sd.MustLog = mustLog
+		snapshot := sd.snapshot()
+		s.mu.Unlock()
 
-		exp, _ := exporters.Load().(exportersMap)
-		mustExport := len(exp) > 0
-		if mustExport {
-			for e := range exp {
-				e.ExportSpan(sd)
-			}
+		if s.tracer != nil {
+			s.tracer.provider.export(snapshot)
 		}
-
-		// str, _ := json.Marshal(sd)
-		// fmt.Printf("==> end span => endOnce %s\n", str)
 	})
 }
 
@@ -221,24 +568,81 @@ func startExecutionTracerTask(ctx context.Context, name string) (context.Context
 	return nctx, task.End
 }
 
-func startSpanInternal(name string, hasParent bool, parent spancontext.SpanContext) *Span {
-	span := &Span{}
+// startProfilerLabels sets the pprof labels of the goroutine to the labels of
+// ctx plus the span name and attrs. The returned function restores the labels
+// of ctx.
+func startProfilerLabels(ctx context.Context, name string, attrs []trace.Attribute) (context.Context, func()) {
+	labels := make([]string, 0, 2+2*len(attrs))
+	labels = append(labels, "span", name)
+	for _, a := range attrs {
+		labels = append(labels, a.Key, attributeString(a.Value))
+	}
+	nctx := pprof.WithLabels(ctx, pprof.Labels(labels...))
+	pprof.SetGoroutineLabels(nctx)
+	return nctx, func() {
+		pprof.SetGoroutineLabels(ctx)
+	}
+}
+
+func startSpanInternal(tr *Tracer, name string, hasParent bool, parent spancontext.SpanContext, remoteParent bool, o StartOptions) *Span {
+	p := tr.provider
+	span := &Span{tracer: tr, limits: p.limits}
 	span.spanContext = parent
 
+	idGen := p.idGenerator()
 	if !hasParent {
-		span.spanContext.TraceID = id.TraceID(id.TraceGen.NewTraceID())
+		span.spanContext = spancontext.SpanContext{
+			TraceID: id.TraceID(idGen.NewTraceID()),
+		}
+	}
+	span.spanContext.SpanID = id.SpanID(idGen.NewSpanID())
+
+	sampler := o.Sampler
+	if atomic.LoadInt32(&p.isShutdown) == 1 {
+		sampler = NeverSample()
+	}
+	if sampler == nil && hasParent && !remoteParent {
+		// keep the decision of the local parent
+		span.spanContext.TraceOptions.SetIsSampled(parent.IsSampled())
+	} else {
+		if sampler == nil {
+			sampler = p.loadSampler()
+		}
+		span.spanContext.TraceOptions.SetIsSampled(sampler(SamplingParameters{
+			ParentContext:   parent,
+			TraceID:         span.spanContext.TraceID,
+			SpanID:          span.spanContext.SpanID,
+			Name:            name,
+			HasRemoteParent: remoteParent,
+		}).Sample)
+	}
+
+	if !span.spanContext.IsSampled() {
+		return span
 	}
-	span.spanContext.SpanID = id.SpanID(id.TraceGen.NewSpanID())
 
 	span.data = &SpanData{
 		SpanContext: span.spanContext,
 		StartTime:   time.Now(),
-		//SpanKind:        o.SpanKind,
-		Name: name,
+		SpanKind:    o.SpanKind,
+		Name:        name,
+		Resource:    p.resource,
+	}
+	for _, l := range o.Links {
+		if !withinLimit(len(span.data.Links), span.limits.LinkCountLimit) {
+			span.data.DroppedLinkCount++
+			continue
+		}
+		if l.attrs != nil {
+			l.Attributes, l.DroppedAttributeCount = span.limits.attributesMap(l.attrs)
+			l.attrs = nil
+		}
+		span.data.Links = append(span.data.Links, l)
 	}
 
 	if hasParent {
 		span.data.ParentSpanID = parent.SpanID
+		span.data.HasRemoteParent = remoteParent
 	}
 
 	return span
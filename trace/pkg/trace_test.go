@@ -0,0 +1,236 @@
+package tracepkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/thnthien/great-deku/trace"
+)
+
+func TestSpan_AddEvent(t *testing.T) {
+	_, span := StartSpan(context.Background(), "lookup")
+	span.AddEvent("cache miss", trace.Attribute{Key: "key", Value: "user:1"})
+	span.AddEvent("retry")
+	span.End()
+
+	sd := span.GetSpanData().(*SpanData)
+	if len(sd.Events) != 2 {
+		t.Fatalf("got %d events, want 2", len(sd.Events))
+	}
+	if sd.Events[0].Name != "cache miss" || sd.Events[0].Attributes["key"] != "user:1" {
+		t.Fatalf("unexpected event %+v", sd.Events[0])
+	}
+	if sd.Events[0].Time.Before(sd.StartTime) || sd.Events[1].Time.Before(sd.Events[0].Time) {
+		t.Fatal("events are not timestamped in order")
+	}
+
+	b, _ := json.Marshal(sd)
+	if !strings.Contains(string(b), `"Name":"cache miss"`) {
+		t.Fatalf("events not rendered: %s", b)
+	}
+
+	if s := otlpSpanFromData(sd); len(s.Events) != 2 || s.Events[0].Name != "cache miss" {
+		t.Fatalf("otlp events = %+v", s.Events)
+	}
+	z := (&ZipkinExporter{}).zipkinSpanFromData(sd)
+	if len(z.Annotations) != 2 || z.Annotations[0].Value != "cache miss key=user:1" {
+		t.Fatalf("zipkin annotations = %+v", z.Annotations)
+	}
+}
+
+func TestSpan_Links(t *testing.T) {
+	_, producer1 := StartSpan(context.Background(), "produce")
+	_, producer2 := StartSpan(context.Background(), "produce")
+
+	_, consumer := StartSpan(context.Background(), "consume batch",
+		WithLinks(NewLink(producer1.(*Span).SpanContext(), trace.Attribute{Key: "offset", Value: int64(1)})))
+	consumer.AddLink(producer2.GetTraceID(), producer2.GetSpanID())
+	consumer.End()
+
+	sd := consumer.GetSpanData().(*SpanData)
+	if len(sd.Links) != 2 {
+		t.Fatalf("got %d links, want 2", len(sd.Links))
+	}
+	if string(sd.Links[0].TraceID) != producer1.GetTraceID() || sd.Links[0].Attributes["offset"] != int64(1) {
+		t.Fatalf("unexpected link %+v", sd.Links[0])
+	}
+	if string(sd.Links[1].SpanID) != producer2.GetSpanID() {
+		t.Fatalf("unexpected link %+v", sd.Links[1])
+	}
+
+	if s := otlpSpanFromData(sd); len(s.Links) != 2 || s.Links[1].TraceID != producer2.GetTraceID() {
+		t.Fatalf("otlp links = %+v", s.Links)
+	}
+	z := (&ZipkinExporter{}).zipkinSpanFromData(sd)
+	if !strings.Contains(z.Tags["links"], producer2.GetTraceID()+"-"+producer2.GetSpanID()) {
+		t.Fatalf("zipkin links = %q", z.Tags["links"])
+	}
+}
+
+func TestSpan_KindAndStatus(t *testing.T) {
+	_, span := StartSpan(context.Background(), "handle", WithSpanKind(SpanKindServer))
+	span.SetStatus(trace.StatusCodeOK, "ignored")
+	span.End()
+	sd := span.GetSpanData().(*SpanData)
+	if sd.SpanKind != SpanKindServer || sd.Status != (Status{Code: trace.StatusCodeOK}) {
+		t.Fatalf("kind = %v, status = %+v", sd.SpanKind, sd.Status)
+	}
+	b, _ := json.Marshal(sd)
+	if !strings.Contains(string(b), `"SpanKind":"SERVER"`) || !strings.Contains(string(b), `"Code":"OK"`) {
+		t.Fatalf("kind or status not serialized: %s", b)
+	}
+	if s := otlpSpanFromData(sd); s.Kind != 2 || s.Status.Code != 1 {
+		t.Fatalf("otlp kind = %d, status = %+v", s.Kind, s.Status)
+	}
+	if z := (&ZipkinExporter{}).zipkinSpanFromData(sd); z.Kind != "SERVER" || z.Tags["error"] != "" {
+		t.Fatalf("zipkin kind = %q, tags = %v", z.Kind, z.Tags)
+	}
+}
+
+func TestSpan_SetError(t *testing.T) {
+	_, span := StartSpan(context.Background(), "query")
+	span.SetError(&json.SyntaxError{})
+	span.End()
+
+	sd := span.GetSpanData().(*SpanData)
+	if !span.IsError() || sd.Status.Code != trace.StatusCodeError {
+		t.Fatalf("status = %+v", sd.Status)
+	}
+	if len(sd.Events) != 1 || sd.Events[0].Name != "exception" ||
+		sd.Events[0].Attributes["exception.type"] != "*json.SyntaxError" {
+		t.Fatalf("exception event = %+v", sd.Events)
+	}
+	if s := otlpSpanFromData(sd); s.Kind != 1 || s.Status.Code != 2 {
+		t.Fatalf("otlp kind = %d, status = %+v", s.Kind, s.Status)
+	}
+}
+
+func TestSpan_SetErrorNil(t *testing.T) {
+	_, span := StartSpan(context.Background(), "retried")
+	span.SetError(errors.New("first attempt failed"))
+	span.SetError(nil)
+	if span.IsError() {
+		t.Fatal("SetError(nil) did not clear the error")
+	}
+
+	_, span = StartSpan(context.Background(), "explicit status")
+	span.SetStatus(trace.StatusCodeError, "quota exceeded")
+	span.SetError(nil)
+	if !span.IsError() {
+		t.Fatal("SetError(nil) cleared a status set by SetStatus")
+	}
+}
+
+func TestSpan_ConcurrentMutation(t *testing.T) {
+	exp := newCollectExporter(t)
+	ctx, parent := StartSpan(context.Background(), "parent")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, child := StartSpan(ctx, "child")
+			parent.SetAttribute(fmt.Sprintf("child.%d", i), int64(i))
+			parent.AddEvent("child started")
+			parent.AddLink(child.GetTraceID(), child.GetSpanID())
+			parent.SetWarnDuration(time.Second)
+			parent.SetStatus(trace.StatusCodeOK, "")
+			_ = parent.IsError()
+			_ = parent.GetSpanData()
+			child.End()
+		}(i)
+	}
+	wg.Wait()
+	parent.End()
+
+	sd := exp.byName("parent")
+	if sd.ChildSpanCount != 20 || len(sd.Attributes) != 20 || len(sd.Events) != 20 || len(sd.Links) != 20 {
+		t.Fatalf("children = %d, attributes = %d, events = %d, links = %d",
+			sd.ChildSpanCount, len(sd.Attributes), len(sd.Events), len(sd.Links))
+	}
+}
+
+func TestSpan_MutationAfterEnd(t *testing.T) {
+	exp := newCollectExporter(t)
+	ctx, span := StartSpan(context.Background(), "ended")
+	span.SetAttribute("before", true)
+	span.End()
+
+	span.SetAttribute("after", true)
+	span.SetError(errors.New("late"))
+	span.AddEvent("late")
+	span.AddLink("4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7")
+	_, child := StartSpan(ctx, "late child")
+	child.End()
+	span.End()
+
+	sd := exp.byName("ended")
+	if len(sd.Attributes) != 1 || sd.IsError() || len(sd.Events) != 0 || len(sd.Links) != 0 || sd.ChildSpanCount != 0 {
+		t.Fatalf("span mutated after End: %+v", sd)
+	}
+	if span.IsError() {
+		t.Fatal("SetError after End was applied")
+	}
+}
+
+func TestSpan_SnapshotIsolation(t *testing.T) {
+	_, span := StartSpan(context.Background(), "snapshot")
+	span.SetAttribute("k", "v1")
+	before := span.GetSpanData().(*SpanData)
+	span.SetAttribute("k", "v2")
+	span.AddEvent("e")
+	if before.Attributes["k"] != "v1" || len(before.Events) != 0 {
+		t.Fatalf("snapshot changed with the span: %+v", before)
+	}
+	before.Attributes["k"] = "mutated"
+	if span.GetSpanData().(*SpanData).Attributes["k"] != "v2" {
+		t.Fatal("mutating a snapshot changed the span")
+	}
+}
+
+func TestSpan_ProfilerLabels(t *testing.T) {
+	goroutineLabels := func() string {
+		var buf bytes.Buffer
+		_ = pprof.Lookup("goroutine").WriteTo(&buf, 1)
+		return buf.String()
+	}
+
+	base := pprof.WithLabels(context.Background(), pprof.Labels("job", "import"))
+	pprof.SetGoroutineLabels(base)
+	defer pprof.SetGoroutineLabels(context.Background())
+
+	ctx, span := StartSpan(base, "profiled", WithProfilerLabels(trace.String("tenant", "acme")))
+	if v, _ := pprof.Label(ctx, "span"); v != "profiled" {
+		t.Fatalf("span label = %q", v)
+	}
+	if v, _ := pprof.Label(ctx, "job"); v != "import" {
+		t.Fatalf("previous label lost: %q", v)
+	}
+	if !strings.Contains(goroutineLabels(), `"span":"profiled"`) {
+		t.Fatal("goroutine labels not set")
+	}
+	span.End()
+	if got := goroutineLabels(); strings.Contains(got, `"span":"profiled"`) || !strings.Contains(got, `"job":"import"`) {
+		t.Fatal("goroutine labels not restored")
+	}
+	if span.(*Span).data.Attributes["tenant"] != "acme" {
+		t.Fatal("profiler attribute not added to the span")
+	}
+
+	// A second End must not reset the labels of a span started meanwhile.
+	_, next := StartSpan(base, "next", WithProfilerLabels())
+	span.End()
+	if !strings.Contains(goroutineLabels(), `"span":"next"`) {
+		t.Fatal("second End reset the goroutine labels")
+	}
+	next.End()
+}
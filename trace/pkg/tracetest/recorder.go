@@ -0,0 +1,159 @@
+// Package tracetest provides helpers to assert on the spans produced by code
+// instrumented with tracepkg.
+package tracetest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	tracepkg "github.com/thnthien/great-deku/trace/pkg"
+)
+
+// Recorder is an exporter that keeps every ended span in memory.
+type Recorder struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	spans []*tracepkg.SpanData
+}
+
+// NewRecorder returns a Recorder registered as a global exporter for the
+// duration of the test. It is unregistered when the test and its subtests
+// complete.
+//
+// Spans ended by tests running in parallel are recorded too, so use
+// NewProvider when tests use t.Parallel.
+func NewRecorder(t testing.TB) *Recorder {
+	t.Helper()
+	r := newRecorder()
+	tracepkg.RegisterExporter(r)
+	t.Cleanup(func() {
+		tracepkg.UnregisterExporter(r)
+	})
+	return r
+}
+
+func newRecorder() *Recorder {
+	r := &Recorder{}
+	r.cond = sync.NewCond(&r.mu)
+	return r
+}
+
+// ExportSpan implements tracepkg.Exporter.
+func (r *Recorder) ExportSpan(sd *tracepkg.SpanData) {
+	r.mu.Lock()
+	r.spans = append(r.spans, sd)
+	r.mu.Unlock()
+	r.cond.Broadcast()
+}
+
+// Ended returns the recorded spans in the order they ended.
+func (r *Recorder) Ended() []*tracepkg.SpanData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*tracepkg.SpanData(nil), r.spans...)
+}
+
+// Reset forgets every recorded span.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	r.spans = nil
+	r.mu.Unlock()
+}
+
+// ByName returns the recorded spans with the given name.
+func (r *Recorder) ByName(name string) []*tracepkg.SpanData {
+	var out []*tracepkg.SpanData
+	for _, sd := range r.Ended() {
+		if sd.Name == name {
+			out = append(out, sd)
+		}
+	}
+	return out
+}
+
+// ByTraceID returns the recorded spans of the given trace.
+func (r *Recorder) ByTraceID(traceID string) []*tracepkg.SpanData {
+	var out []*tracepkg.SpanData
+	for _, sd := range r.Ended() {
+		if string(sd.TraceID) == traceID {
+			out = append(out, sd)
+		}
+	}
+	return out
+}
+
+// Children returns the recorded spans whose parent is the given span.
+func (r *Recorder) Children(parent *tracepkg.SpanData) []*tracepkg.SpanData {
+	var out []*tracepkg.SpanData
+	for _, sd := range r.Ended() {
+		if sd.TraceID == parent.TraceID && sd.ParentSpanID == parent.SpanID {
+			out = append(out, sd)
+		}
+	}
+	return out
+}
+
+// MustFind returns the only recorded span with the given name and fails the
+// test if there is none or more than one.
+func (r *Recorder) MustFind(t testing.TB, name string) *tracepkg.SpanData {
+	t.Helper()
+	spans := r.ByName(name)
+	if len(spans) != 1 {
+		t.Fatalf("tracetest: found %d spans named %q, want 1", len(spans), name)
+	}
+	return spans[0]
+}
+
+// AssertChildOf fails the test unless the span named child is a direct child
+// of the span named parent within the same trace.
+func (r *Recorder) AssertChildOf(t testing.TB, child, parent string) {
+	t.Helper()
+	c := r.MustFind(t, child)
+	p := r.MustFind(t, parent)
+	if c.TraceID != p.TraceID || c.ParentSpanID != p.SpanID {
+		t.Fatalf("tracetest: span %q (trace %s, parent %s) is not a child of %q (trace %s, span %s)",
+			child, c.TraceID, c.ParentSpanID, parent, p.TraceID, p.SpanID)
+	}
+}
+
+// WaitFor blocks until at least n spans are recorded or the timeout expires,
+// which is useful when spans end on other goroutines.
+func (r *Recorder) WaitFor(n int, timeout time.Duration) ([]*tracepkg.SpanData, error) {
+	timedOut := false
+	timer := time.AfterFunc(timeout, func() {
+		r.mu.Lock()
+		timedOut = true
+		r.cond.Broadcast()
+		r.mu.Unlock()
+	})
+	defer timer.Stop()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for len(r.spans) < n {
+		if timedOut {
+			return append([]*tracepkg.SpanData(nil), r.spans...),
+				fmt.Errorf("tracetest: recorded %d spans after %s, want %d", len(r.spans), timeout, n)
+		}
+		r.cond.Wait()
+	}
+	return append([]*tracepkg.SpanData(nil), r.spans...), nil
+}
+
+// NewProvider returns a TracerProvider that exports to a new Recorder only.
+// Spans started from it, and their local children started with
+// tracepkg.StartSpan, never reach the exporters of other providers, so tests
+// using it can run in parallel. The provider is shut down when the test
+// completes.
+func NewProvider(t testing.TB, opts ...tracepkg.ProviderOption) (*tracepkg.TracerProvider, *Recorder) {
+	t.Helper()
+	r := newRecorder()
+	p := tracepkg.NewTracerProvider(append(opts, tracepkg.WithExporters(r))...)
+	t.Cleanup(func() {
+		_ = p.Shutdown(context.Background())
+	})
+	return p, r
+}
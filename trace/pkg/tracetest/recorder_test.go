@@ -0,0 +1,71 @@
+package tracetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	tracepkg "github.com/thnthien/great-deku/trace/pkg"
+)
+
+func TestRecorder(t *testing.T) {
+	rec := NewRecorder(t)
+
+	ctx, root := tracepkg.StartSpan(context.Background(), "root")
+	_, child := tracepkg.StartSpan(ctx, "child")
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		child.End()
+		root.End()
+	}()
+
+	spans, err := rec.WaitFor(2, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans", len(spans))
+	}
+	rec.AssertChildOf(t, "child", "root")
+	if c := rec.Children(rec.MustFind(t, "root")); len(c) != 1 || c[0].Name != "child" {
+		t.Fatalf("children = %+v", c)
+	}
+	if got := rec.ByTraceID(root.GetTraceID()); len(got) != 2 {
+		t.Fatalf("got %d spans for the trace", len(got))
+	}
+
+	rec.Reset()
+	if _, err := rec.WaitFor(1, 10*time.Millisecond); err == nil {
+		t.Fatal("expected a timeout after Reset")
+	}
+}
+
+func TestRecorder_Scoped(t *testing.T) {
+	var first *Recorder
+	t.Run("first", func(t *testing.T) {
+		first = NewRecorder(t)
+	})
+	_, span := tracepkg.StartSpan(context.Background(), "after")
+	span.End()
+	if n := len(first.Ended()); n != 0 {
+		t.Fatalf("recorder of a finished test recorded %d spans", n)
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	global := NewRecorder(t)
+	provider, rec := NewProvider(t)
+
+	ctx, root := provider.Tracer("test").Start(context.Background(), "root")
+	_, child := tracepkg.StartSpan(ctx, "child")
+	child.End()
+	root.End()
+
+	if n := len(rec.Ended()); n != 2 {
+		t.Fatalf("provider recorder got %d spans, want 2", n)
+	}
+	rec.AssertChildOf(t, "child", "root")
+	if n := len(global.ByTraceID(root.GetTraceID())); n != 0 {
+		t.Fatalf("default provider got %d spans of the scoped trace", n)
+	}
+}